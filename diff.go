@@ -0,0 +1,140 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/mirror"
+)
+
+// crawlDiff is the report written by the diff command.
+type crawlDiff struct {
+	*grawler.GraphDiff
+	ChangedMenus []string `json:",omitempty"`
+}
+
+// diff implements the diff command. It compares the dotfiles of two crawls and
+// reports the differences. If mirror manifests of both crawls are given, menus
+// whose content changed are reported as well.
+func diff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	flagFormat := fs.String("format", "text", "the output format: \"text\" or \"json\"")
+	flagOldMirror := fs.String("old-mirror", "", "the mirror directory or manifest file of the old crawl")
+	flagNewMirror := fs.String("new-mirror", "", "the mirror directory or manifest file of the new crawl")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: grawler diff [flags] old.dot new.dot\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 2 || (*flagFormat != "text" && *flagFormat != "json") ||
+		(*flagOldMirror == "") != (*flagNewMirror == "") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	old, err := readGraph(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	g, err := readGraph(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	d := &crawlDiff{GraphDiff: g.Diff(old)}
+
+	if *flagOldMirror != "" {
+		oldManifest, err := readManifest(*flagOldMirror)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		manifest, err := readManifest(*flagNewMirror)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		d.ChangedMenus = manifest.ChangedMenus(oldManifest)
+	}
+
+	if *flagFormat == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "\t")
+		err = e.Encode(d)
+	} else {
+		err = d.writeText(os.Stdout, *flagOldMirror != "")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readManifest reads the manifest of a mirror. name is either the mirror
+// directory or the manifest file itself.
+func readManifest(name string) (mirror.Manifest, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return mirror.ReadManifest(name)
+	}
+	return mirror.ReadManifestFile(name)
+}
+
+// writeText writes a human readable report to w. Changed menus are only
+// reported if menus is true.
+func (d *crawlDiff) writeText(w io.Writer, menus bool) error {
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Gopherholes appeared", d.Appeared},
+		{"Gopherholes disappeared", d.Disappeared},
+		{"Gopherholes died", d.Died},
+		{"Gopherholes came back", d.Revived},
+		{"Relations added", edgeStrings(d.AddedEdges)},
+		{"Relations removed", edgeStrings(d.RemovedEdges)},
+	}
+	if menus {
+		sections = append(sections, struct {
+			title string
+			items []string
+		}{"Menus changed", d.ChangedMenus})
+	}
+
+	for i, s := range sections {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %d\n", s.title, len(s.items))
+		for _, item := range s.items {
+			if _, err := fmt.Fprintf(w, "  %s\n", item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func edgeStrings(edges []grawler.Edge) []string {
+	s := make([]string, len(edges))
+	for i, e := range edges {
+		s[i] = fmt.Sprintf("%s -> %s", e.From, e.To)
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// graphFilterFlags holds the values of the flags selecting grawler.GraphFilters.
+type graphFilterFlags struct {
+	keepAlive     *bool
+	dropSelfLoops *bool
+	dropDeadEdges *bool
+	style         *bool
+	around        *string
+	hops          *int
+}
+
+// addGraphFilterFlags defines the flags selecting grawler.GraphFilters in
+// flag.FlagSet fs.
+func addGraphFilterFlags(fs *flag.FlagSet) *graphFilterFlags {
+	return &graphFilterFlags{
+		keepAlive:     fs.Bool("keep-alive", false, "keep only alive gopherholes"),
+		dropSelfLoops: fs.Bool("drop-self-loops", false, "drop relations of gopherholes to themselves"),
+		dropDeadEdges: fs.Bool("drop-dead-edges", false, "drop relations to gopherholes that are not alive"),
+		style:         fs.Bool("style", false, "color gopherholes that are not alive and their relations red"),
+		around:        fs.String("around", "", "keep only the gopherholes around this one (host:port), see -hops"),
+		hops:          fs.Int("hops", 1, "the number of hops around the gopherhole given by -around"),
+	}
+}
+
+// filters returns the selected grawler.GraphFilters in the order they have to
+// be applied.
+func (f *graphFilterFlags) filters() []grawler.GraphFilter {
+	var filters []grawler.GraphFilter
+	if *f.around != "" {
+		n := *f.around
+		if h, err := grawler.ParseHost(n); err == nil {
+			n = h.String()
+		}
+		filters = append(filters, grawler.Neighbourhood(n, *f.hops))
+	}
+	if *f.keepAlive {
+		filters = append(filters, grawler.KeepAlive)
+	}
+	if *f.dropSelfLoops {
+		filters = append(filters, grawler.DropSelfLoops)
+	}
+	if *f.dropDeadEdges {
+		filters = append(filters, grawler.DropDeadEdges)
+	}
+	if *f.style {
+		filters = append(filters, grawler.StyleByStatus)
+	}
+	return filters
+}
+
+// filter implements the filter command. It post-processes the dotfile written
+// by a previous crawl.
+func filter(args []string) {
+	fs := flag.NewFlagSet("filter", flag.ExitOnError)
+	flagOutput := fs.String("o", "", "the dotfile to write (empty for stdout)")
+	ff := addGraphFilterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: grawler filter [flags] grawler.dot\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	g, err := readGraph(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if h, err := grawler.ParseHost(*ff.around); *ff.around != "" && (err != nil || g.Nodes[h.String()] == nil) {
+		fmt.Fprintf(os.Stderr, "Unknown gopherhole: %q\n", *ff.around)
+		os.Exit(1)
+	}
+
+	g.Filter(ff.filters()...)
+
+	if *flagOutput != "" {
+		err = writeGraph(*flagOutput, g)
+	} else {
+		_, err = g.WriteTo(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
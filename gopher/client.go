@@ -0,0 +1,249 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package gopher
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default timeouts used by a Client.
+const (
+	DefaultDialTimeout = 5 * time.Second
+	DefaultTimeout     = time.Minute
+)
+
+// Dialer establishes connections to gopher servers. *net.Dialer implements
+// Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Client is a gopher client. The zero value is a usable Client using the
+// default timeouts. A Client is safe for concurrent use.
+type Client struct {
+	// Dialer is used to connect to gopher servers. A *net.Dialer is used
+	// if Dialer is nil.
+	Dialer Dialer
+
+	// DialTimeout limits the time to establish a connection,
+	// DefaultDialTimeout is used if DialTimeout is 0.
+	DialTimeout time.Duration
+
+	// Timeout limits the time of a whole request, including reading the
+	// response. DefaultTimeout is used if Timeout is 0.
+	Timeout time.Duration
+}
+
+// Error is returned by the Client if a request failed.
+type Error struct {
+	Op       string // "dial", "request" or "read"
+	Addr     string
+	Selector string
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gopher %s %s %q: %v", e.Op, e.Addr, e.Selector, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// PlusError is returned by Client.Plus if the server responded with a Gopher+
+// error.
+type PlusError struct {
+	Code    int
+	Message string
+}
+
+func (e *PlusError) Error() string {
+	return fmt.Sprintf("Gopher+ error %d: %s", e.Code, e.Message)
+}
+
+// Open sends the request line to the gopher server at addr ("host:port") and
+// returns the connection to read the raw response from.
+func (c *Client) Open(ctx context.Context, addr, request string) (io.ReadCloser, error) {
+	selector := request
+	if i := strings.IndexByte(request, '\t'); i >= 0 {
+		selector = request[:i]
+	}
+
+	dialTimeout := c.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+	if err != nil {
+		return nil, &Error{"dial", addr, selector, err}
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	err = conn.SetDeadline(deadline)
+	if err == nil {
+		_, err = fmt.Fprintf(conn, "%s\r\n", request)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, &Error{"request", addr, selector, err}
+	}
+
+	return conn, nil
+}
+
+// readCloser combines a Reader reading from a connection with the Closer of
+// the connection.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Menu fetches the gopher menu selector from the server at addr.
+func (c *Client) Menu(ctx context.Context, addr, selector string) ([]*Item, error) {
+	return c.menu(ctx, addr, selector, selector)
+}
+
+// Search sends query to the search server (item type 7) selector at addr and
+// returns the resulting menu.
+func (c *Client) Search(ctx context.Context, addr, selector, query string) ([]*Item, error) {
+	return c.menu(ctx, addr, selector, selector+"\t"+query)
+}
+
+// menu implements Menu and Search.
+func (c *Client) menu(ctx context.Context, addr, selector, request string) ([]*Item, error) {
+	rc, err := c.Open(ctx, addr, request)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	items, err := ReadMenu(rc)
+	if err != nil {
+		return items, &Error{"read", addr, selector, err}
+	}
+	return items, nil
+}
+
+// Text fetches the text document selector from the server at addr. The
+// returned io.ReadCloser reads the document as described by NewTextReader.
+func (c *Client) Text(ctx context.Context, addr, selector string) (io.ReadCloser, error) {
+	rc, err := c.Open(ctx, addr, selector)
+	if err != nil {
+		return nil, err
+	}
+	return readCloser{NewTextReader(rc), rc}, nil
+}
+
+// Binary fetches the binary file selector from the server at addr. The
+// returned io.ReadCloser reads the file until the server closes the
+// connection.
+func (c *Client) Binary(ctx context.Context, addr, selector string) (io.ReadCloser, error) {
+	return c.Open(ctx, addr, selector)
+}
+
+// Plus sends the Gopher+ request for selector to the server at addr. request
+// is the Gopher+ field of the request, e.g. "+" for the default
+// representation, "+text/plain" for a specific representation or "!" for the
+// attributes of the item. The response header is read and the returned
+// io.ReadCloser reads the data announced by it. A *PlusError is returned if
+// the server responded with an error.
+func (c *Client) Plus(ctx context.Context, addr, selector, request string) (io.ReadCloser, error) {
+	rc, err := c.Open(ctx, addr, selector+"\t"+request)
+	if err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(rc)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		rc.Close()
+		return nil, &Error{"read", addr, selector, err}
+	}
+	header = strings.TrimRight(header, "\r\n")
+
+	length, err := parsePlusHeader(header)
+	if err != nil {
+		rc.Close()
+		return nil, &Error{"read", addr, selector, err}
+	}
+
+	if header[0] == '-' {
+		defer rc.Close()
+		var msg []byte
+		msg, err = io.ReadAll(dataReader(r, length))
+		if err != nil {
+			return nil, &Error{"read", addr, selector, err}
+		}
+		return nil, plusError(string(msg))
+	}
+
+	return readCloser{dataReader(r, length), rc}, nil
+}
+
+// parsePlusHeader parses the header line of a Gopher+ response and returns the
+// announced data length.
+func parsePlusHeader(header string) (int64, error) {
+	if len(header) < 2 || (header[0] != '+' && header[0] != '-') {
+		return 0, fmt.Errorf("Invalid Gopher+ header: %q", header)
+	}
+	length, err := strconv.ParseInt(header[1:], 10, 64)
+	if err != nil || length < -2 {
+		return 0, fmt.Errorf("Invalid Gopher+ header: %q", header)
+	}
+	return length, nil
+}
+
+// dataReader returns an io.Reader reading the data of a Gopher+ response with
+// the announced length from r: -1 is terminated by a "." line, -2 by closing
+// the connection, any other length is the number of bytes.
+func dataReader(r io.Reader, length int64) io.Reader {
+	switch length {
+	case -1:
+		return NewTextReader(r)
+	case -2:
+		return r
+	}
+	return io.LimitReader(r, length)
+}
+
+// plusError parses the data of a Gopher+ error response. The first line
+// starts with the error code, followed by the error message.
+func plusError(msg string) *PlusError {
+	msg = strings.TrimSpace(msg)
+	var code int
+	if i := strings.IndexAny(msg, " \t\n"); i >= 0 {
+		if c, err := strconv.Atoi(msg[:i]); err == nil {
+			code = c
+			msg = strings.TrimSpace(msg[i:])
+		}
+	} else if c, err := strconv.Atoi(msg); err == nil {
+		code = c
+		msg = ""
+	}
+	return &PlusError{code, msg}
+}
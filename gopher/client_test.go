@@ -0,0 +1,193 @@
+package gopher
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pipeDialer is a Dialer connecting to an in-memory gopher server answering
+// requests with the responses in the map.
+type pipeDialer map[string]string
+
+func (d pipeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if address != "example.com:70" {
+		return nil, errors.New("connection refused")
+	}
+
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		request, err := bufio.NewReader(server).ReadString('\n')
+		if err != nil {
+			return
+		}
+		io.WriteString(server, d[strings.TrimRight(request, "\r\n")])
+	}()
+	return client, nil
+}
+
+var testDialer = pipeDialer{
+	"":                 "1Foo\t/foo\texample.com\t70\r\niinfo\t\terror.host\t1\r\n.\r\n",
+	"/search\tgopher":  "0Result\t/result\texample.com\t70\r\n.\r\n",
+	"/text":            "Hello\r\n..World\r\n.\r\n",
+	"/bin":             "\x00\x01.\r\n\x02",
+	"/plus\t+":         "+-1\r\nHello\r\n.\r\n",
+	"/plus\t+raw":      "+-2\r\nraw.\r\n",
+	"/plus\t!":         "+5\r\n+INFOxxx",
+	"/missing\t+":      "--1\r\n1 Item is not available.\r\n.\r\n",
+	"/malformed\t+":    "foo\r\n",
+	"/malformedmenu":   "1Foo\r\n.\r\n",
+	"/missingterm\t+":  "+-1\r\nHello\r\n",
+	"/emptyerror\t+":   "--2\r\n",
+	"/trailing\t+text": "+3\r\nabcdef",
+}
+
+func TestClientMenu(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+	items, err := c.Menu(context.Background(), "example.com:70", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []*Item{
+		{'1', "Foo", "/foo", "example.com", "70", false},
+		{'i', "info", "", "error.host", "1", false},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("%d != %d", len(items), len(expected))
+	}
+	for i := range items {
+		if *items[i] != *expected[i] {
+			t.Errorf("%v != %v", items[i], expected[i])
+		}
+	}
+}
+
+func TestClientMenuMalformed(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+	_, err := c.Menu(context.Background(), "example.com:70", "/malformedmenu")
+	if !errors.Is(err, ErrMalformedLine) {
+		t.Errorf("%v is not ErrMalformedLine", err)
+	}
+}
+
+func TestClientSearch(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+	items, err := c.Search(context.Background(), "example.com:70", "/search", "gopher")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 1 || items[0].Selector != "/result" {
+		t.Errorf("%v != %v", items, "[/result]")
+	}
+}
+
+var clientReadTests = []struct {
+	name     string
+	fetch    func(c *Client) (io.ReadCloser, error)
+	expected string
+}{
+	{"text", func(c *Client) (io.ReadCloser, error) {
+		return c.Text(context.Background(), "example.com:70", "/text")
+	}, "Hello\n.World\n"},
+	{"binary", func(c *Client) (io.ReadCloser, error) {
+		return c.Binary(context.Background(), "example.com:70", "/bin")
+	}, "\x00\x01.\r\n\x02"},
+	{"plus", func(c *Client) (io.ReadCloser, error) {
+		return c.Plus(context.Background(), "example.com:70", "/plus", "+")
+	}, "Hello\n"},
+	{"plus until close", func(c *Client) (io.ReadCloser, error) {
+		return c.Plus(context.Background(), "example.com:70", "/plus", "+raw")
+	}, "raw.\r\n"},
+	{"plus length", func(c *Client) (io.ReadCloser, error) {
+		return c.Plus(context.Background(), "example.com:70", "/plus", "!")
+	}, "+INFO"},
+	{"plus missing terminator", func(c *Client) (io.ReadCloser, error) {
+		return c.Plus(context.Background(), "example.com:70", "/missingterm", "+")
+	}, "Hello\n"},
+	{"plus trailing data", func(c *Client) (io.ReadCloser, error) {
+		return c.Plus(context.Background(), "example.com:70", "/trailing", "+text")
+	}, "abc"},
+}
+
+func TestClientRead(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+	for _, tt := range clientReadTests {
+		rc, err := tt.fetch(c)
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", tt.name, err)
+			continue
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Errorf("%s: Unexpected error: %v", tt.name, err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("%s: %q != %q", tt.name, b, tt.expected)
+		}
+	}
+}
+
+func TestClientPlusError(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+
+	_, err := c.Plus(context.Background(), "example.com:70", "/missing", "+")
+	var plusErr *PlusError
+	if !errors.As(err, &plusErr) {
+		t.Fatalf("%v is not a *PlusError", err)
+	}
+	if plusErr.Code != 1 || plusErr.Message != "Item is not available." {
+		t.Errorf("%v != %v", plusErr, &PlusError{1, "Item is not available."})
+	}
+
+	_, err = c.Plus(context.Background(), "example.com:70", "/emptyerror", "+")
+	if !errors.As(err, &plusErr) {
+		t.Errorf("%v is not a *PlusError", err)
+	}
+
+	_, err = c.Plus(context.Background(), "example.com:70", "/malformed", "+")
+	var gopherErr *Error
+	if !errors.As(err, &gopherErr) || gopherErr.Op != "read" {
+		t.Errorf("%v is not a read *Error", err)
+	}
+}
+
+func TestClientDialError(t *testing.T) {
+	c := &Client{Dialer: testDialer}
+	_, err := c.Menu(context.Background(), "example.org:70", "")
+
+	var gopherErr *Error
+	if !errors.As(err, &gopherErr) {
+		t.Fatalf("%v is not a *Error", err)
+	}
+	if gopherErr.Op != "dial" {
+		t.Errorf("%q != %q", gopherErr.Op, "dial")
+	}
+}
+
+// stallingDialer is a Dialer connecting to a gopher server that never
+// responds.
+type stallingDialer struct{}
+
+func (stallingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go io.Copy(io.Discard, server)
+	return client, nil
+}
+
+func TestClientTimeout(t *testing.T) {
+	c := &Client{Dialer: stallingDialer{}, Timeout: 10 * time.Millisecond}
+	_, err := c.Menu(context.Background(), "example.com:70", "")
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("%v is not a timeout", err)
+	}
+}
@@ -0,0 +1,282 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// A client for the gopher protocol (RFC 1436) and Gopher+.
+package gopher
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Item types used by this package.
+const (
+	TypeText      = '0'
+	TypeDirectory = '1'
+	TypeError     = '3'
+	TypeSearch    = '7'
+	TypeBinary    = '9'
+	TypeInfo      = 'i'
+)
+
+// Token is the type describing a field position of a token in a gopher menu
+// line.
+type Token int
+
+// Tokens of a valid gopher menu line.
+const (
+	TTypeAndDescription Token = iota // item type and descriptive text
+	TSelector                        // Selector string
+	THostname                        // Host
+	TPort                            // Port
+	TPlus                            // Additional field used by Gopher+
+)
+
+// ErrMalformedLine is returned by ParseItem if a line is not a valid menu
+// line.
+var ErrMalformedLine = errors.New("Malformed Gopher line")
+
+// Item is an item of a gopher menu.
+type Item struct {
+	Type     byte
+	Display  string
+	Selector string
+	Hostname string
+	Port     string
+
+	// Plus is true if the item has been marked as Gopher+ item.
+	Plus bool
+}
+
+// ParseItem parses a gopher menu line, without line ending, and returns the
+// corresponding *Item. An error wrapping ErrMalformedLine is returned for
+// invalid lines.
+func ParseItem(line string) (*Item, error) {
+	t := strings.Split(line, "\t")
+
+	valid := true
+	switch {
+	case len(t) < 4 || len(t) > 5:
+		// at least four fields are required for a valid gopher line, at
+		// most five fields are allowed for a valid gopher+ line
+		valid = false
+	case len(t[THostname]) == 0 || len(t[TPort]) == 0:
+		// host and port must not be empty
+		valid = false
+	case strings.Contains(t[THostname], " ") || strings.Contains(t[TPort], " "):
+		// host and port must not contain spaces
+		valid = false
+	case strings.Contains(t[THostname], "/"):
+		// host and port must not contain slashes
+		valid = false
+	case len(t) == 5 && t[TPlus] != "+":
+		// for a valid gopher+ line the fifth fields has to be "+"
+		valid = false
+	case len(t[TTypeAndDescription]) == 0:
+		// an item type is required (first byte of TTypeAndDescription)
+		valid = false
+	}
+	if !valid {
+		return nil, fmt.Errorf("%w: %q", ErrMalformedLine, line)
+	}
+
+	return &Item{
+		t[TTypeAndDescription][0],
+		t[TTypeAndDescription][1:],
+		t[TSelector],
+		t[THostname],
+		t[TPort],
+		len(t) == 5,
+	}, nil
+}
+
+// String returns the menu line of the Item, without line ending.
+func (i *Item) String() string {
+	s := fmt.Sprintf("%c%s\t%s\t%s\t%s", i.Type, i.Display, i.Selector, i.Hostname, i.Port)
+	if i.Plus {
+		s += "\t+"
+	}
+	return s
+}
+
+// DefaultMaxLineLength is the maximum length of a menu line used by
+// MenuScanner, if no other length is given.
+const DefaultMaxLineLength = bufio.MaxScanTokenSize
+
+// MenuScanner reads the lines of a gopher menu. Scanning stops at the
+// terminating "." line, or at the end of the input if the terminator is
+// missing.
+type MenuScanner struct {
+	in            *errorReader
+	scan          *bufio.Scanner
+	maxLineLength int
+	err           error
+}
+
+// errorReader records the error returned by r.
+type errorReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// NewMenuScanner returns a new MenuScanner reading from r. Lines longer than
+// maxLineLength bytes, without line ending, stop scanning with the error
+// bufio.ErrTooLong. If maxLineLength is not positive, DefaultMaxLineLength is
+// used. If reading from r fails, the incomplete last line is not returned.
+func NewMenuScanner(r io.Reader, maxLineLength int) *MenuScanner {
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
+	}
+
+	s := &MenuScanner{in: &errorReader{r: r}, maxLineLength: maxLineLength}
+	s.scan = bufio.NewScanner(s.in)
+	// Leave room for the line ending.
+	s.scan.Buffer(make([]byte, 0, 4096), maxLineLength+2)
+	s.scan.Split(s.split)
+	return s
+}
+
+// split works like bufio.ScanLines, but accepts CR, LF and CR LF as line
+// endings. It fails instead of returning the last line without line ending if
+// reading failed.
+func (s *MenuScanner) split(data []byte, atEOF bool) (int, []byte, error) {
+	err := s.in.err
+	i := bytes.IndexAny(data, "\r\n")
+	switch {
+	case i < 0:
+		if atEOF && err != nil && err != io.EOF {
+			return 0, nil, err
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		// Request more data.
+		return 0, nil, nil
+	case data[i] == '\n':
+		return i + 1, data[:i], nil
+	case i+1 < len(data):
+		if data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	case atEOF:
+		return i + 1, data[:i], nil
+	}
+	// A LF might follow the CR.
+	return 0, nil, nil
+}
+
+// Scan advances the MenuScanner to the next line, which is then available
+// through the Text method. It returns false when scanning stops.
+func (s *MenuScanner) Scan() bool {
+	if s.err != nil || !s.scan.Scan() {
+		return false
+	}
+	if s.scan.Text() == "." {
+		// This is the end marker of the directory listing.
+		return false
+	}
+	if len(s.scan.Text()) > s.maxLineLength {
+		s.err = bufio.ErrTooLong
+		return false
+	}
+	return true
+}
+
+// Text returns the current line, without line ending.
+func (s *MenuScanner) Text() string {
+	return s.scan.Text()
+}
+
+// Item parses the current line.
+func (s *MenuScanner) Item() (*Item, error) {
+	return ParseItem(s.Text())
+}
+
+// Err returns the first error encountered by the MenuScanner.
+func (s *MenuScanner) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.scan.Err()
+}
+
+// ReadMenu reads all items of the gopher menu from r.
+func ReadMenu(r io.Reader) ([]*Item, error) {
+	var items []*Item
+	scan := NewMenuScanner(r, 0)
+	for scan.Scan() {
+		i, err := scan.Item()
+		if err != nil {
+			return items, err
+		}
+		items = append(items, i)
+	}
+	return items, scan.Err()
+}
+
+// textReader implements NewTextReader.
+type textReader struct {
+	r    *bufio.Reader
+	buf  []byte // unread part of the current line
+	done bool
+}
+
+// NewTextReader returns an io.Reader reading a gopher text document from r.
+// Line endings are converted to "\n" and leading dots are unstuffed. Reading
+// stops at the terminating "." line, or at the end of the input if the
+// terminator is missing.
+func NewTextReader(r io.Reader) io.Reader {
+	return &textReader{r: bufio.NewReader(r)}
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	for len(t.buf) == 0 {
+		if t.done {
+			return 0, io.EOF
+		}
+
+		line, err := t.r.ReadBytes('\n')
+		if err == io.EOF {
+			t.done = true
+			if len(line) == 0 {
+				return 0, io.EOF
+			}
+		} else if err != nil {
+			return 0, err
+		}
+
+		eol := bytes.HasSuffix(line, []byte("\n"))
+		line = bytes.TrimRight(line, "\r\n")
+		if string(line) == "." {
+			t.done = true
+			return 0, io.EOF
+		}
+		if bytes.HasPrefix(line, []byte("..")) {
+			line = line[1:]
+		}
+		if eol {
+			line = append(line, '\n')
+		}
+		t.buf = line
+	}
+
+	n := copy(p, t.buf)
+	t.buf = t.buf[n:]
+	return n, nil
+}
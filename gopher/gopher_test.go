@@ -0,0 +1,116 @@
+package gopher
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var parseItemTests = []struct {
+	line     string
+	expected *Item
+}{
+	{"1Foo\t/foo\texample.com\t70", &Item{'1', "Foo", "/foo", "example.com", "70", false}},
+	{"0Bar\t\texample.com\t7070\t+", &Item{'0', "Bar", "", "example.com", "7070", true}},
+	{"iinfo\t\terror.host\t1", &Item{'i', "info", "", "error.host", "1", false}},
+	{"1Foo\t/foo\texample.com", nil},
+	{"1Foo\t/foo\texample.com\t70\t-", nil},
+	{"1Foo\t/foo\t\t70", nil},
+	{"1Foo\t/foo\texample com\t70", nil},
+	{"1Foo\t/foo\texample.com/\t70", nil},
+	{"\t/foo\texample.com\t70", nil},
+}
+
+func TestParseItem(t *testing.T) {
+	for _, tt := range parseItemTests {
+		i, err := ParseItem(tt.line)
+		if tt.expected == nil {
+			if !errors.Is(err, ErrMalformedLine) {
+				t.Errorf("%q: %v is not ErrMalformedLine", tt.line, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: Unexpected error: %v", tt.line, err)
+			continue
+		}
+		if *i != *tt.expected {
+			t.Errorf("%v != %v", i, tt.expected)
+		}
+		if i.String() != tt.line {
+			t.Errorf("%q != %q", i.String(), tt.line)
+		}
+	}
+}
+
+var menuScannerTests = []struct {
+	menu     string
+	expected []string
+	err      error
+}{
+	{"iA\t\th\t1\r\niB\t\th\t1\r\n.\r\niC\t\th\t1\r\n", []string{"iA\t\th\t1", "iB\t\th\t1"}, nil},
+	{"iA\t\th\t1\niB\t\th\t1\n", []string{"iA\t\th\t1", "iB\t\th\t1"}, nil},
+	{"iA\t\th\t1\r\n.", []string{"iA\t\th\t1"}, nil},
+	{"iA\t\th\t1\riB\t\th\t1\r.\riC\t\th\t1\r", []string{"iA\t\th\t1", "iB\t\th\t1"}, nil},
+	{"iA\t\th\t1\r\riB\t\th\t1\n\r\n", []string{"iA\t\th\t1", "", "iB\t\th\t1", ""}, nil},
+	{"iA\t\th\t1\r\ni" + strings.Repeat("x", 20) + "\t\th\t1\r\n", []string{"iA\t\th\t1"}, bufio.ErrTooLong},
+}
+
+func TestMenuScanner(t *testing.T) {
+	for _, tt := range menuScannerTests {
+		var lines []string
+		scan := NewMenuScanner(strings.NewReader(tt.menu), 20)
+		for scan.Scan() {
+			lines = append(lines, scan.Text())
+		}
+		if !errors.Is(scan.Err(), tt.err) {
+			t.Errorf("%v != %v", scan.Err(), tt.err)
+		}
+		if strings.Join(lines, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%q != %q", lines, tt.expected)
+		}
+	}
+}
+
+var textReaderTests = []struct {
+	text     string
+	expected string
+}{
+	{"foo\r\nbar\r\n.\r\n", "foo\nbar\n"},
+	{"foo\nbar\n.\nbaz\n", "foo\nbar\n"},
+	{"..foo\r\n...\r\n.bar\r\n.\r\n", ".foo\n..\n.bar\n"},
+	{"foo\r\nbar", "foo\nbar"},
+	{"", ""},
+}
+
+func TestTextReader(t *testing.T) {
+	for _, tt := range textReaderTests {
+		b, err := io.ReadAll(NewTextReader(strings.NewReader(tt.text)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("%q != %q", b, tt.expected)
+		}
+	}
+}
+
+func TestMenuScannerReadError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("iA\t\th\t1\r\n1B\t/b\th\t70"), iotest.ErrReader(errRead))
+
+	var lines []string
+	scan := NewMenuScanner(r, 0)
+	for scan.Scan() {
+		lines = append(lines, scan.Text())
+	}
+	if !errors.Is(scan.Err(), errRead) {
+		t.Errorf("%v != %v", scan.Err(), errRead)
+	}
+	if len(lines) != 1 || lines[0] != "iA\t\th\t1" {
+		t.Errorf("%q != %q", lines, []string{"iA\t\th\t1"})
+	}
+}
@@ -0,0 +1,253 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+)
+
+// HostResolver implements a way to resolve a hostname to its IP addresses.
+type HostResolver func(hostname string) ([]string, error)
+
+// NetHostResolver is a HostResolver using the resolver of the net package. IP
+// addresses resolve to themselves.
+func NetHostResolver(hostname string) ([]string, error) {
+	return net.LookupHost(hostname)
+}
+
+// StaticHostResolver returns a HostResolver that resolves hostnames using map
+// m. Hostnames are looked up in lower case. IP addresses not contained in m
+// resolve to themselves.
+func StaticHostResolver(m map[string][]string) HostResolver {
+	return func(hostname string) ([]string, error) {
+		if addrs, ok := m[strings.ToLower(hostname)]; ok {
+			return addrs, nil
+		}
+		if net.ParseIP(hostname) != nil {
+			return []string{hostname}, nil
+		}
+		return nil, fmt.Errorf("Could not resolve host: %q", hostname)
+	}
+}
+
+// AliasGroup is a group of Hosts that have been identified as the same gopher
+// server. The Canonical Host is the one used to represent the whole group.
+type AliasGroup struct {
+	Canonical *Host
+	Aliases   []*Host
+}
+
+// ResolveAliases groups hosts that are served by the same gopher server. Two
+// hosts are considered the same server if they share an IP address and port
+// and serve an identical root menu. Root menus are fetched using ResourceOpener
+// o, but only for hosts sharing an address with at least one other host.
+//
+// Hosts that can not be resolved or whose root menu can not be fetched are
+// never grouped. Only groups with at least one alias are returned.
+func ResolveAliases(hosts []*Host, resolve HostResolver, o ResourceOpener) []AliasGroup {
+	byHost := make(map[string]*Host)
+	byAddr := make(map[string][]string)
+	for _, h := range hosts {
+		key := h.String()
+		if _, ok := byHost[key]; ok {
+			continue
+		}
+		byHost[key] = h
+
+		addrs, err := resolve(h.Hostname)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			addr := strings.ToLower(net.JoinHostPort(a, h.Port))
+			byAddr[addr] = append(byAddr[addr], key)
+		}
+	}
+
+	digests := make(map[string]string)
+	digest := func(key string) (string, bool) {
+		if d, ok := digests[key]; ok {
+			return d, d != ""
+		}
+		d, err := rootMenuDigest(o, byHost[key])
+		if err != nil {
+			d = ""
+		}
+		digests[key] = d
+		return d, d != ""
+	}
+
+	u := newUnionFind()
+	for _, keys := range byAddr {
+		if len(keys) < 2 {
+			continue
+		}
+
+		first := make(map[string]string)
+		for _, key := range keys {
+			d, ok := digest(key)
+			if !ok {
+				continue
+			}
+			if f, ok := first[d]; ok {
+				u.union(f, key)
+			} else {
+				first[d] = key
+			}
+		}
+	}
+
+	var groups []AliasGroup
+	for _, members := range u.sets() {
+		if len(members) < 2 {
+			continue
+		}
+
+		sort.Slice(members, func(i, j int) bool {
+			return canonicalLess(byHost[members[i]], byHost[members[j]])
+		})
+		g := AliasGroup{Canonical: byHost[members[0]]}
+		for _, m := range members[1:] {
+			g.Aliases = append(g.Aliases, byHost[m])
+		}
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Canonical.String() < groups[j].Canonical.String()
+	})
+
+	return groups
+}
+
+// canonicalLess orders hosts by their suitability as canonical name of an
+// AliasGroup: hostnames are preferred over IP addresses, shorter hostnames
+// over longer ones.
+func canonicalLess(a, b *Host) bool {
+	aIP, bIP := net.ParseIP(a.Hostname) != nil, net.ParseIP(b.Hostname) != nil
+	if aIP != bIP {
+		return bIP
+	}
+	if len(a.Hostname) != len(b.Hostname) {
+		return len(a.Hostname) < len(b.Hostname)
+	}
+	return a.String() < b.String()
+}
+
+// rootMenuDigest fetches the root menu of Host h using ResourceOpener o and
+// returns a digest of its content.
+func rootMenuDigest(o ResourceOpener, h *Host) (string, error) {
+	rc, err := o(&Resource{h, DirectoryType, ""})
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, rc); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+// unionFind is a minimal disjoint-set structure over strings.
+type unionFind struct {
+	parent map[string]string
+}
+
+func newUnionFind() *unionFind {
+	return &unionFind{make(map[string]string)}
+}
+
+func (u *unionFind) find(s string) string {
+	p, ok := u.parent[s]
+	if !ok {
+		u.parent[s] = s
+		return s
+	}
+	if p == s {
+		return s
+	}
+	r := u.find(p)
+	u.parent[s] = r
+	return r
+}
+
+func (u *unionFind) union(a, b string) {
+	ra, rb := u.find(a), u.find(b)
+	if ra != rb {
+		u.parent[rb] = ra
+	}
+}
+
+func (u *unionFind) sets() [][]string {
+	m := make(map[string][]string)
+	for s := range u.parent {
+		r := u.find(s)
+		m[r] = append(m[r], s)
+	}
+
+	sets := make([][]string, 0, len(m))
+	for _, s := range m {
+		sets = append(sets, s)
+	}
+	return sets
+}
+
+// MarkAliases adds a sameAs edge from the canonical Host to every alias of the
+// AliasGroups to Graph g.
+func (g *Graph) MarkAliases(groups []AliasGroup) {
+	for _, ag := range groups {
+		for _, a := range ag.Aliases {
+			g.AddEdge(ag.Canonical.String(), a.String(), Attributes{"sameAs": "true"})
+		}
+	}
+}
+
+// MergeAliases merges the nodes of all aliases of the AliasGroups into the node
+// of the canonical Host. Edges are redirected accordingly. A merged node is
+// alive if any of its aliases was alive, its aliases are listed in the aliases
+// attribute.
+func (g *Graph) MergeAliases(groups []AliasGroup) {
+	canonical := make(map[string]string)
+	for _, ag := range groups {
+		c := ag.Canonical.String()
+		names := make([]string, 0, len(ag.Aliases))
+		for _, a := range ag.Aliases {
+			canonical[a.String()] = c
+			names = append(names, a.String())
+		}
+
+		g.AddNode(c, Attributes{"aliases": strings.Join(names, " ")})
+		for _, n := range names {
+			if g.Alive(n) {
+				g.AddNode(c, Attributes{"alive": "true"})
+			}
+		}
+	}
+
+	rename := func(n string) string {
+		if c, ok := canonical[n]; ok {
+			return c
+		}
+		return n
+	}
+
+	edges := g.Edges
+	g.Edges = make(map[Edge]Attributes)
+	for e, a := range edges {
+		g.AddEdge(rename(e.From), rename(e.To), a)
+	}
+
+	for n := range canonical {
+		delete(g.Nodes, n)
+	}
+}
@@ -0,0 +1,121 @@
+package grawler
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+var aliasResolver = StaticHostResolver(map[string][]string{
+	"2f30.org":          {"95.85.40.183"},
+	"dl.2f30.org":       {"95.85.40.183"},
+	"u.2f30.org":        {"95.85.40.183"},
+	"gopher.r-36.net":   {"178.79.185.175"},
+	"r-36.net":          {"178.79.185.175", "2a01:7e00::1"},
+	"gopherproject.org": {"178.79.185.175"},
+})
+
+var aliasMenus = map[string]string{
+	"2f30.org:70":          "i2f30\t\t2f30.org\t70\r\n.\r\n",
+	"dl.2f30.org:70":       "i2f30\t\t2f30.org\t70\r\n.\r\n",
+	"u.2f30.org:70":        "iusers\t\tu.2f30.org\t70\r\n.\r\n",
+	"178.79.185.175:70":    "ir-36\t\tr-36.net\t70\r\n.\r\n",
+	"gopher.r-36.net:70":   "ir-36\t\tr-36.net\t70\r\n.\r\n",
+	"r-36.net:70":          "ir-36\t\tr-36.net\t70\r\n.\r\n",
+	"gopherproject.org:70": "igopherproject\t\tgopherproject.org\t70\r\n.\r\n",
+}
+
+func aliasOpener(r *Resource) (io.ReadCloser, error) {
+	m, ok := aliasMenus[r.Host.String()]
+	if !ok {
+		return nil, fmt.Errorf("Connection refused: %v", r.Host)
+	}
+	return newStringReadCloser(m), nil
+}
+
+func TestResolveAliases(t *testing.T) {
+	hosts := []*Host{
+		{"2f30.org", "70"},
+		{"dl.2f30.org", "70"},
+		{"u.2f30.org", "70"},
+		{"178.79.185.175", "70"},
+		{"gopher.r-36.net", "70"},
+		{"R-36.net", "70"},
+		{"gopherproject.org", "70"},
+		{"unresolvable.example.com", "70"},
+		{"2f30.org", "7070"},
+	}
+
+	groups := ResolveAliases(hosts, aliasResolver, aliasOpener)
+
+	expected := []string{
+		"2f30.org:70 [dl.2f30.org:70]",
+		"r-36.net:70 [gopher.r-36.net:70 178.79.185.175:70]",
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("Unexpected alias groups: %v", groups)
+	}
+	for i, e := range expected {
+		s := fmt.Sprintf("%v %v", groups[i].Canonical, groups[i].Aliases)
+		if s != e {
+			t.Errorf("%q != %q", s, e)
+		}
+	}
+}
+
+func aliasTestGraph() *Graph {
+	g := NewGraph()
+	g.AddNode("2f30.org:70", Attributes{"alive": "true"})
+	g.AddEdge("2f30.org:70", "dl.2f30.org:70", nil)
+	g.AddEdge("dl.2f30.org:70", "r-36.net:70", nil)
+	g.AddNode("dl.2f30.org:70", Attributes{"alive": "true"})
+	g.AddEdge("r-36.net:70", "dl.2f30.org:70", nil)
+	return g
+}
+
+var aliasTestGroups = []AliasGroup{
+	{&Host{"2f30.org", "70"}, []*Host{{"dl.2f30.org", "70"}}},
+}
+
+func TestGraphMarkAliases(t *testing.T) {
+	g := aliasTestGraph()
+	g.MarkAliases(aliasTestGroups)
+
+	a, ok := g.Edges[Edge{"2f30.org:70", "dl.2f30.org:70"}]
+	if !ok || a["sameAs"] != "true" {
+		t.Fatalf("sameAs edge not found: %v", g.Edges)
+	}
+	if len(g.Nodes) != 3 {
+		t.Fatalf("Unexpected nodes: %v", g.Nodes)
+	}
+}
+
+func TestGraphMergeAliases(t *testing.T) {
+	g := aliasTestGraph()
+	g.MergeAliases(aliasTestGroups)
+
+	if _, ok := g.Nodes["dl.2f30.org:70"]; ok {
+		t.Errorf("Alias node not merged: %v", g.Nodes)
+	}
+	if !g.Alive("2f30.org:70") {
+		t.Errorf("Merged node not alive: %v", g.Nodes)
+	}
+	if g.Nodes["2f30.org:70"]["aliases"] != "dl.2f30.org:70" {
+		t.Errorf("Unexpected aliases attribute: %v", g.Nodes)
+	}
+
+	expected := []Edge{
+		{"2f30.org:70", "2f30.org:70"},
+		{"2f30.org:70", "r-36.net:70"},
+		{"r-36.net:70", "2f30.org:70"},
+	}
+	edges := g.SortedEdges()
+	if len(edges) != len(expected) {
+		t.Fatalf("Unexpected edges: %v", edges)
+	}
+	for i, e := range expected {
+		if edges[i] != e {
+			t.Errorf("%v != %v", edges[i], e)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package grawler_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/grawlertest"
+)
+
+// discard is a dotfile discarding everything written.
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+func (discard) Close() error                { return nil }
+
+// BenchmarkCrawlSynthetic crawls synthetic gopherspaces of growing size. It
+// reports the crawled menus and findings per second and the heap in use at
+// the end of the crawl. Run a single size using e.g.
+//
+//	go test -run XXX -bench CrawlSynthetic/degree=powerlaw/hosts=100000 ./grawler
+func BenchmarkCrawlSynthetic(b *testing.B) {
+	for _, degree := range []grawlertest.Degree{grawlertest.DegreeUniform, grawlertest.DegreePowerLaw} {
+		for _, hosts := range []int{1000, 10000, 100000} {
+			name := fmt.Sprintf("degree=%v/hosts=%d", degree, hosts)
+			b.Run(name, func(b *testing.B) {
+				s := grawlertest.NewSynthetic(grawlertest.SyntheticConfig{
+					Hosts:     hosts,
+					Menus:     4,
+					MaxDegree: 8,
+					Degree:    degree,
+					DeadRatio: 0.2,
+					TrapRatio: 0.05,
+					TrapDepth: 10,
+					Seed:      1,
+				})
+				b.ReportAllocs()
+
+				var stats *grawlertest.CrawlStats
+				for i := 0; i < b.N; i++ {
+					var err error
+					stats, err = grawlertest.Crawl(context.Background(), s.Opener(), s.Root(), 8, discard{})
+					if err != nil {
+						b.Fatalf("Unexpected error: %v", err)
+					}
+				}
+
+				secs := stats.Duration.Seconds()
+				b.ReportMetric(float64(stats.Menus), "menus")
+				b.ReportMetric(float64(stats.Menus)/secs, "menus/s")
+				b.ReportMetric(float64(stats.Findings)/secs, "findings/s")
+				b.ReportMetric(float64(stats.HeapAlloc)/(1<<20), "heap-MB")
+			})
+		}
+	}
+}
+
+// BenchmarkCrawlSyntheticVisited crawls a synthetic gopherspace keeping the
+// visited strings in the different VisitedSets. Compare the heap in use and
+// the crawled menus, false positives of the BloomSet skip menus.
+func BenchmarkCrawlSyntheticVisited(b *testing.B) {
+	s := grawlertest.NewSynthetic(grawlertest.SyntheticConfig{
+		Hosts:     100000,
+		Menus:     4,
+		MaxDegree: 8,
+		Degree:    grawlertest.DegreePowerLaw,
+		DeadRatio: 0.2,
+		TrapRatio: 0.05,
+		TrapDepth: 10,
+		Seed:      1,
+	})
+
+	sets := []struct {
+		name   string
+		newSet func(b *testing.B) grawler.VisitedSet
+	}{
+		{"map", func(b *testing.B) grawler.VisitedSet { return grawler.NewMapSet() }},
+		{"disk", func(b *testing.B) grawler.VisitedSet {
+			d, err := grawler.NewDiskSet(b.TempDir())
+			if err != nil {
+				b.Fatalf("Unexpected error: %v", err)
+			}
+			b.Cleanup(func() { d.Close() })
+			return d
+		}},
+		{"bloom", func(b *testing.B) grawler.VisitedSet { return grawler.NewBloomSet(1<<20, 0.001) }},
+	}
+	for _, set := range sets {
+		b.Run("visited="+set.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			var stats *grawlertest.CrawlStats
+			for i := 0; i < b.N; i++ {
+				newSet := func() grawler.VisitedSet { return set.newSet(b) }
+				var err error
+				stats, err = grawlertest.CrawlWithVisited(context.Background(), s.Opener(), s.Root(), 8, discard{}, newSet)
+				if err != nil {
+					b.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			secs := stats.Duration.Seconds()
+			b.ReportMetric(float64(stats.Menus), "menus")
+			b.ReportMetric(float64(stats.Menus)/secs, "menus/s")
+			b.ReportMetric(float64(stats.HeapAlloc)/(1<<20), "heap-MB")
+		})
+	}
+}
@@ -0,0 +1,265 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrHostBroken is returned by the ResourceOpener of a Breaker for hosts whose
+// circuit is open.
+var ErrHostBroken = errors.New("Host broken")
+
+// HostBrokenError is returned by Breaker.Allow for hosts whose circuit is
+// open. It matches ErrHostBroken.
+type HostBrokenError struct {
+	Host *Host
+	Err  error // last failure of the host
+
+	// Wait is the time until the circuit allows the next probe. While a
+	// probe is running, it is a tenth of the cool-down.
+	Wait time.Duration
+
+	// ProbeFailed is true, if a probe of the host has failed since the
+	// circuit has been opened.
+	ProbeFailed bool
+}
+
+func (e *HostBrokenError) Error() string {
+	return fmt.Sprintf("%v: %v (%v)", ErrHostBroken, e.Host, e.Err)
+}
+
+func (e *HostBrokenError) Is(target error) bool {
+	return target == ErrHostBroken
+}
+
+// BreakerState is the state of the circuit of a single host.
+type BreakerState int
+
+// States of a circuit.
+const (
+	BreakerClosed   BreakerState = iota // resources are opened
+	BreakerOpen                         // resources fail fast
+	BreakerHalfOpen                     // a single probe is allowed
+)
+
+// String returns the name of the BreakerState.
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// hostCircuit tracks the failures of a single host.
+type hostCircuit struct {
+	state    BreakerState
+	failures int // consecutive failures
+	trips    int // number of times the circuit has been opened
+	opened   time.Time
+	probing  bool
+	failed   bool // a probe has failed since the circuit has been opened
+	lastErr  error
+}
+
+// Breaker is a per-host circuit breaker. After Threshold consecutive failures
+// to connect to a host, its circuit opens and opening further resources of the
+// host fails fast with ErrHostBroken. After Cooldown a single probe is allowed:
+// if it succeeds the circuit closes again, otherwise it stays open for another
+// Cooldown. A Breaker is safe for concurrent use.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mtx   sync.Mutex
+	hosts map[string]*hostCircuit
+	now   func() time.Time
+}
+
+// NewBreaker creates and initializes a new Breaker.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		Threshold: threshold,
+		Cooldown:  cooldown,
+		hosts:     make(map[string]*hostCircuit),
+		now:       time.Now,
+	}
+}
+
+// circuit returns the circuit of Host h. The caller has to hold the lock.
+func (b *Breaker) circuit(h *Host) *hostCircuit {
+	c, ok := b.hosts[h.String()]
+	if !ok {
+		c = &hostCircuit{}
+		b.hosts[h.String()] = c
+	}
+	return c
+}
+
+// State returns the state of the circuit of Host h.
+func (b *Breaker) State(h *Host) BreakerState {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	c, ok := b.hosts[h.String()]
+	if !ok {
+		return BreakerClosed
+	}
+	return c.state
+}
+
+// Allow returns nil, if a resource of Host h may be opened. Otherwise a
+// *HostBrokenError is returned. If nil is returned, the outcome has to be
+// reported using Success, Failure or Release.
+func (b *Breaker) Allow(h *Host) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	c := b.circuit(h)
+	wait := b.Cooldown / 10
+	switch c.state {
+	case BreakerOpen:
+		if d := b.now().Sub(c.opened); d < b.Cooldown {
+			wait = b.Cooldown - d
+			break
+		}
+		c.state = BreakerHalfOpen
+		fallthrough
+	case BreakerHalfOpen:
+		if c.probing {
+			break
+		}
+		c.probing = true
+		return nil
+	default:
+		return nil
+	}
+
+	return &HostBrokenError{h, c.lastErr, wait, c.failed}
+}
+
+// Success reports that a resource of Host h has been opened successfully. The
+// circuit of the host is closed.
+func (b *Breaker) Success(h *Host) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	c := b.circuit(h)
+	c.state = BreakerClosed
+	c.failures = 0
+	c.probing = false
+	c.failed = false
+}
+
+// Failure reports that a resource of Host h could not be opened because of
+// err. The circuit of the host is opened if the Threshold is reached or the
+// failed attempt was a probe.
+func (b *Breaker) Failure(h *Host, err error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	c := b.circuit(h)
+	c.failures++
+	c.lastErr = err
+
+	switch {
+	case c.state == BreakerHalfOpen:
+		c.state = BreakerOpen
+		c.opened = b.now()
+		c.probing = false
+		c.failed = true
+	case c.state == BreakerClosed && c.failures >= b.Threshold:
+		c.state = BreakerOpen
+		c.opened = b.now()
+		c.trips++
+	}
+}
+
+// Release reports that opening a resource of Host h allowed by Allow failed
+// for a reason unrelated to connecting to the host. The state of the circuit
+// does not change, but another probe is allowed.
+func (b *Breaker) Release(h *Host) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.circuit(h).probing = false
+}
+
+// Opener returns a ResourceOpener that opens resources using ResourceOpener o,
+// unless the circuit of their host is open. Errors returned by o matching
+// ErrDial are reported as failures. Other errors, e.g. of a replayed archive,
+// and errors while reading the resources are not.
+func (b *Breaker) Opener(o ResourceOpener) ResourceOpener {
+	return func(r *Resource) (io.ReadCloser, error) {
+		if err := b.Allow(r.Host); err != nil {
+			return nil, err
+		}
+
+		rc, err := o(r)
+		switch {
+		case err == nil:
+			b.Success(r.Host)
+		case errors.Is(err, ErrDial):
+			b.Failure(r.Host, err)
+		default:
+			b.Release(r.Host)
+		}
+		return rc, err
+	}
+}
+
+// BrokenHost describes a host whose circuit has been opened at least once.
+type BrokenHost struct {
+	Host     string
+	State    BreakerState
+	Failures int // consecutive failures
+	Trips    int // number of times the circuit has been opened
+	LastErr  error
+}
+
+// Attributes returns the dot attributes describing the BrokenHost, suitable
+// for Grapher.AddNodeAttributes.
+func (bh BrokenHost) Attributes() Attributes {
+	a := Attributes{
+		"breaker":  bh.State.String(),
+		"failures": strconv.Itoa(bh.Failures),
+	}
+	if bh.LastErr != nil {
+		a["error"] = ErrorClass(bh.LastErr)
+	}
+	return a
+}
+
+// BrokenHosts returns all hosts whose circuit has been opened at least once,
+// ordered by their Host string.
+func (b *Breaker) BrokenHosts() []BrokenHost {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	var broken []BrokenHost
+	for h, c := range b.hosts {
+		if c.trips == 0 {
+			continue
+		}
+		broken = append(broken, BrokenHost{h, c.state, c.failures, c.trips, c.lastErr})
+	}
+	sort.Slice(broken, func(i, j int) bool {
+		return broken[i].Host < broken[j].Host
+	})
+	return broken
+}
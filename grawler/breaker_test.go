@@ -0,0 +1,202 @@
+package grawler
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	down := true
+	opened := 0
+	o := b.Opener(func(r *Resource) (io.ReadCloser, error) {
+		opened++
+		if down {
+			return nil, &DialError{r.Host, errRefused}
+		}
+		return newStringReadCloser(""), nil
+	})
+
+	h := &Host{"down.example.com", "70"}
+	r := &Resource{h, DirectoryType, ""}
+	other := &Resource{&Host{"up.example.com", "70"}, DirectoryType, ""}
+
+	steps := []struct {
+		advance time.Duration
+		down    bool
+		r       *Resource
+		broken  bool // expect ErrHostBroken
+		opened  int  // expected calls of the wrapped opener
+		state   BreakerState
+	}{
+		{0, true, r, false, 1, BreakerClosed},
+		{0, true, r, false, 2, BreakerClosed},
+		{0, true, r, false, 3, BreakerOpen},
+		{0, true, r, true, 3, BreakerOpen},
+		{30 * time.Second, true, r, true, 3, BreakerOpen},
+		{0, false, other, false, 4, BreakerOpen},
+		// The cool-down is over, the probe fails.
+		{30 * time.Second, true, r, false, 5, BreakerOpen},
+		{0, true, r, true, 5, BreakerOpen},
+		// The next probe succeeds.
+		{time.Minute, false, r, false, 6, BreakerClosed},
+		{0, false, r, false, 7, BreakerClosed},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+		down = s.down
+
+		rc, err := o(s.r)
+		if rc != nil {
+			rc.Close()
+		}
+		if errors.Is(err, ErrHostBroken) != s.broken {
+			t.Errorf("Step %d: unexpected error: %v", i, err)
+		}
+		if opened != s.opened {
+			t.Errorf("Step %d: %d != %d", i, opened, s.opened)
+		}
+		if st := b.State(h); st != s.state {
+			t.Errorf("Step %d: %v != %v", i, st, s.state)
+		}
+	}
+
+	broken := b.BrokenHosts()
+	if len(broken) != 1 || broken[0].Host != h.String() || broken[0].Trips != 1 {
+		t.Fatalf("Unexpected broken hosts: %v", broken)
+	}
+	a := broken[0].Attributes()
+	if a["breaker"] != "closed" || a["failures"] != "0" || a["error"] != ErrorClassRefused {
+		t.Errorf("Unexpected attributes: %v", a)
+	}
+}
+
+func TestBreakerHalfOpenSingleProbe(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	h := &Host{"localhost", "70"}
+	if err := b.Allow(h); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b.Failure(h, errors.New("timeout"))
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(h); err != nil {
+		t.Fatalf("Probe not allowed: %v", err)
+	}
+	if st := b.State(h); st != BreakerHalfOpen {
+		t.Errorf("%v != %v", st, BreakerHalfOpen)
+	}
+	if err := b.Allow(h); !errors.Is(err, ErrHostBroken) {
+		t.Errorf("Second probe allowed: %v", err)
+	}
+}
+
+func TestBreakerHostBrokenError(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	h := &Host{"localhost", "70"}
+	b.Allow(h)
+	b.Failure(h, errRefused)
+
+	steps := []struct {
+		advance     time.Duration
+		probe       bool // expect a probe to be allowed
+		wait        time.Duration
+		probeFailed bool
+	}{
+		{20 * time.Second, false, 40 * time.Second, false},
+		{40 * time.Second, true, 0, false},
+		// The probe is running.
+		{0, false, 6 * time.Second, false},
+	}
+	for i, s := range steps {
+		now = now.Add(s.advance)
+
+		err := b.Allow(h)
+		if (err == nil) != s.probe {
+			t.Fatalf("Step %d: unexpected error: %v", i, err)
+		}
+		if err == nil {
+			continue
+		}
+
+		var hbErr *HostBrokenError
+		if !errors.As(err, &hbErr) {
+			t.Fatalf("Step %d: %T is not *HostBrokenError", i, err)
+		}
+		if hbErr.Wait != s.wait || hbErr.ProbeFailed != s.probeFailed {
+			t.Errorf("Step %d: %v, %v != %v, %v", i, hbErr.Wait, hbErr.ProbeFailed, s.wait, s.probeFailed)
+		}
+	}
+
+	b.Failure(h, errRefused)
+	var hbErr *HostBrokenError
+	if err := b.Allow(h); !errors.As(err, &hbErr) || !hbErr.ProbeFailed || hbErr.Wait != time.Minute {
+		t.Errorf("Unexpected error after failed probe: %#v", err)
+	}
+}
+
+func TestBreakerOpenerNonDialErrors(t *testing.T) {
+	b := NewBreaker(1, time.Minute)
+	errOther := errors.New("Not recorded")
+	o := b.Opener(func(r *Resource) (io.ReadCloser, error) {
+		return nil, errOther
+	})
+
+	h := &Host{"localhost", "70"}
+	for i := 0; i < 3; i++ {
+		if _, err := o(&Resource{h, DirectoryType, ""}); err != errOther {
+			t.Errorf("%v != %v", err, errOther)
+		}
+	}
+	if s := b.State(h); s != BreakerClosed {
+		t.Errorf("%v != %v", s, BreakerClosed)
+	}
+}
+
+func TestBreakerRelease(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := NewBreaker(1, time.Minute)
+	b.now = func() time.Time { return now }
+
+	h := &Host{"localhost", "70"}
+	b.Allow(h)
+	b.Failure(h, errRefused)
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(h); err != nil {
+		t.Fatalf("Probe not allowed: %v", err)
+	}
+	b.Release(h)
+	if err := b.Allow(h); err != nil {
+		t.Errorf("Probe not allowed after release: %v", err)
+	}
+	if s := b.State(h); s != BreakerHalfOpen {
+		t.Errorf("%v != %v", s, BreakerHalfOpen)
+	}
+}
+
+func TestGrapherAddNodeAttributes(t *testing.T) {
+	f := new(mockDotfile)
+	g, err := NewGrapher(f)
+	if err != nil {
+		t.Fatal("NewGrapher failed.")
+	}
+	g.AddNodeAttributes("localhost:70", Attributes{"breaker": "open", "failures": "3"})
+	g.Close()
+
+	expected := "strict digraph {\n\t\"localhost:70\"[breaker=open,failures=3]\n}\n"
+	if f.String() != expected {
+		t.Errorf("%q != %q", f.String(), expected)
+	}
+}
@@ -0,0 +1,220 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// JobResult describes an attempt to crawl a job.
+type JobResult struct {
+	Worker   int // the worker that crawled the job, starting at 1
+	Resource *Resource
+	Attempt  int // the number of the attempt, see Coordinator.Attempts
+	Err      error
+	Duration time.Duration
+	Bytes    int64 // bytes read from the menu
+	Items    int   // items read from the menu
+}
+
+// Crawler crawls all menus reachable from some roots. Jobs are coordinated by
+// a Coordinator and crawled by a number of workers, failed jobs are parked or
+// retried according to a RetryPolicy. This is the crawl loop of the grawler
+// command.
+//
+// The callbacks are optional. OnJobStarted and OnJobFinished are called by the
+// workers concurrently, the others by the goroutine calling Crawl only.
+type Crawler struct {
+	Opener      ResourceOpener
+	Workers     int
+	Limits      Limits
+	ItemActions []ItemActionFunc
+
+	// Retry decides whether failed jobs are parked or retried. Failed jobs
+	// are finished, if Retry is nil.
+	Retry *RetryPolicy
+
+	// Blacklist rejects findings before they are queued.
+	Blacklist Blacklist
+
+	// OnJobStarted is called before a worker crawls *Resource r.
+	OnJobStarted func(worker int, r *Resource)
+
+	// OnJobFinished is called after every attempt to crawl a job.
+	OnJobFinished func(res *JobResult)
+
+	// OnFinding is called for every finding and for the roots, which are
+	// findings without a Parent. err is the *RejectedError of the Blacklist
+	// or the error of Coordinator.QueueJob. Rejected findings are not
+	// queued. The crawl is aborted with the error returned by OnFinding.
+	OnFinding func(f *CrawlFinding, err error) error
+
+	// OnRetry is called if a failed job is parked or retried after delay.
+	OnRetry func(res *JobResult, delay time.Duration)
+
+	// OnJobDone is called if a job is finished, after its last attempt.
+	// The Attempt of res is the final count.
+	OnJobDone func(res *JobResult)
+}
+
+// Crawl crawls all menus reachable from roots, using the Coordinator coord.
+// Crawl returns nil if all jobs have been finished, the error of ctx if it is
+// done before. Crawl returns after all workers have stopped.
+func (c *Crawler) Crawl(ctx context.Context, coord *Coordinator, roots ...*Resource) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, r := range roots {
+		if err := c.queue(coord, &CrawlFinding{r, nil}); err != nil {
+			return err
+		}
+	}
+	if queued, active, finished := coord.Counts(); queued+active+finished == 0 {
+		return nil
+	}
+
+	findings := make(chan *CrawlFinding)
+	done := make(chan *JobResult)
+	stopped := make(chan struct{})
+	defer func() {
+		// Workers might be blocked sending findings of their current
+		// job.
+		cancel()
+		for n := 0; n < c.Workers; {
+			select {
+			case <-findings:
+			case <-stopped:
+				n++
+			}
+		}
+	}()
+	for i := 1; i <= c.Workers; i++ {
+		go func(i int) {
+			defer func() { stopped <- struct{}{} }()
+			c.work(ctx, coord, i, findings, done)
+		}(i)
+	}
+
+	for !coord.JobsExhausted() {
+		select {
+		case f := <-findings:
+			if err := c.queue(coord, f); err != nil {
+				return err
+			}
+		case res := <-done:
+			c.finish(coord, res)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// queue queues the job to crawl the Resource of *CrawlFinding f, unless it is
+// rejected by the Blacklist.
+func (c *Crawler) queue(coord *Coordinator, f *CrawlFinding) error {
+	err := c.Blacklist.Check(f.Resource)
+	if err == nil {
+		err = coord.QueueJob(f.Resource)
+	}
+	if c.OnFinding != nil {
+		return c.OnFinding(f, err)
+	}
+	return nil
+}
+
+// finish finishes, parks or retries the job attempted in *JobResult res.
+// Jobs rejected by the circuit breaker have not been attempted, they wait
+// for the probe of their host.
+func (c *Crawler) finish(coord *Coordinator, res *JobResult) {
+	var delay time.Duration
+	var park, retry bool
+	if c.Retry != nil {
+		delay, park = c.Retry.Park(res.Err)
+		retry = park
+		if !park {
+			delay, retry = c.Retry.Retry(res.Err, res.Attempt)
+		}
+	}
+	if !retry {
+		res.Attempt = coord.FinishJob(res.Resource)
+		if c.OnJobDone != nil {
+			c.OnJobDone(res)
+		}
+		return
+	}
+
+	if park {
+		coord.ParkJob(res.Resource, delay)
+	} else {
+		coord.RetryJob(res.Resource, delay)
+	}
+	if c.OnRetry != nil {
+		c.OnRetry(res, delay)
+	}
+}
+
+// work crawls jobs of the Coordinator coord until all jobs are exhausted or
+// ctx is done. Findings are sent to findings, every attempt is reported to
+// done after all of its findings, so they are queued before the job is
+// finished.
+func (c *Crawler) work(ctx context.Context, coord *Coordinator, worker int, findings chan<- *CrawlFinding, done chan<- *JobResult) {
+	for {
+		r, err := coord.Next(ctx)
+		if err != nil {
+			return
+		}
+		if c.OnJobStarted != nil {
+			c.OnJobStarted(worker, r)
+		}
+
+		res := &JobResult{Worker: worker, Resource: r, Attempt: coord.Attempts(r)}
+		start := time.Now()
+		o := countingOpener(c.Opener, &res.Bytes)
+		ia := append([]ItemActionFunc{func(Item) error {
+			res.Items++
+			return nil
+		}}, c.ItemActions...)
+		res.Err = CrawlWithLimits(o, r, c.Limits, findings, ia...)
+		res.Duration = time.Since(start)
+
+		if c.OnJobFinished != nil {
+			c.OnJobFinished(res)
+		}
+		select {
+		case done <- res:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// countingOpener returns a ResourceOpener that opens resources using
+// ResourceOpener o and adds the number of bytes read from them to n. The
+// returned ResourceOpener is not safe for concurrent use.
+func countingOpener(o ResourceOpener, n *int64) ResourceOpener {
+	return func(r *Resource) (io.ReadCloser, error) {
+		rc, err := o(r)
+		if err != nil {
+			return nil, err
+		}
+		return &countingReadCloser{rc, n}, nil
+	}
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
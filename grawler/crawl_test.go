@@ -0,0 +1,118 @@
+package grawler
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+var crawlerMenus = map[string]string{
+	"": "1A\t/a\tlocalhost\t70\r\n" +
+		"1B\t/b\tlocalhost\t70\r\n" +
+		"1Game\t/game.cgi?x\tlocalhost\t70\r\n" +
+		".",
+	"/a": "1Root\t\tlocalhost\t70\r\n.",
+}
+
+func TestCrawler(t *testing.T) {
+	var mtx sync.Mutex
+	opened := make(map[string]int)
+	o := func(r *Resource) (io.ReadCloser, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		opened[r.Selector]++
+		switch {
+		case r.Selector == "/a" && opened[r.Selector] == 1:
+			return nil, &ReadError{r, errReset}
+		case r.Selector == "/b":
+			return nil, &DialError{r.Host, errRefused}
+		}
+		return newStringReadCloser(crawlerMenus[r.Selector]), nil
+	}
+
+	attempts := make(map[string]int)
+	errs := make(map[string]error)
+	var rejected, duplicates, retries, finished int
+	c := &Crawler{
+		Opener:    o,
+		Workers:   2,
+		Retry:     &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+		Blacklist: Blacklist{".cgi?"},
+		OnJobFinished: func(res *JobResult) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			finished++
+		},
+		OnFinding: func(f *CrawlFinding, err error) error {
+			switch {
+			case errors.Is(err, ErrRejected):
+				rejected++
+			case errors.Is(err, ErrDuplicateJob):
+				duplicates++
+			}
+			return nil
+		},
+		OnRetry: func(res *JobResult, delay time.Duration) {
+			retries++
+		},
+		OnJobDone: func(res *JobResult) {
+			attempts[res.Resource.Selector] = res.Attempt
+			errs[res.Resource.Selector] = res.Err
+		},
+	}
+
+	root := &Resource{testHost, DirectoryType, ""}
+	if err := c.Crawl(context.Background(), NewCoordinator(), root); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	attemptTests := []struct {
+		selector string
+		attempts int
+		err      error
+	}{
+		{"", 1, nil},
+		{"/a", 2, nil},
+		{"/b", 1, ErrDial},
+	}
+	for _, tt := range attemptTests {
+		if a := attempts[tt.selector]; a != tt.attempts {
+			t.Errorf("%q: %d != %d", tt.selector, a, tt.attempts)
+		}
+		if err := errs[tt.selector]; (tt.err == nil && err != nil) || !errors.Is(err, tt.err) {
+			t.Errorf("%q: %v is not %v", tt.selector, err, tt.err)
+		}
+	}
+	if len(attempts) != len(attemptTests) {
+		t.Errorf("%d != %d", len(attempts), len(attemptTests))
+	}
+	if finished != 4 || retries != 1 {
+		t.Errorf("%d, %d != %d, %d", finished, retries, 4, 1)
+	}
+	if rejected != 1 || duplicates != 1 {
+		t.Errorf("%d, %d != %d, %d", rejected, duplicates, 1, 1)
+	}
+}
+
+func TestCrawlerCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Crawler{
+		Opener:  mockResourceOpener,
+		Workers: 4,
+		OnFinding: func(f *CrawlFinding, err error) error {
+			if f.Parent != nil {
+				cancel()
+			}
+			return nil
+		},
+	}
+
+	err := c.Crawl(ctx, NewCoordinator(), testMenu)
+	if err != context.Canceled {
+		t.Errorf("%v != %v", err, context.Canceled)
+	}
+}
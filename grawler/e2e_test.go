@@ -0,0 +1,151 @@
+package grawler_test
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/grawlertest"
+)
+
+var e2eSpec = grawlertest.Spec{
+	"alpha": {
+		Menus: map[string][]grawlertest.Item{
+			"": {
+				{Type: grawler.InformationalMessageType, Display: "Welcome"},
+				{Type: grawler.DirectoryType, Display: "Self", Selector: "/self"},
+				{Type: grawler.DirectoryType, Display: "Beta", Host: "beta"},
+				{Type: grawler.DirectoryType, Display: "Down", Host: "down"},
+				{Type: grawler.DirectoryType, Display: "Down again", Selector: "/again", Host: "down"},
+				{Type: grawler.DirectoryType, Display: "Garbage", Host: "garbage"},
+				{Type: grawler.DirectoryType, Display: "Reset", Host: "reset"},
+				{Type: grawler.DirectoryType, Display: "Hang", Host: "hang"},
+				{Type: '0', Display: "About", Selector: "/about"},
+			},
+			"/self": {{Type: grawler.DirectoryType, Display: "Back"}},
+		},
+	},
+	"beta": {
+		Menus: map[string][]grawlertest.Item{
+			"": {
+				{Type: grawler.DirectoryType, Display: "Truncated", Selector: "/truncated"},
+				{Type: grawler.DirectoryType, Display: "Alpha", Host: "alpha"},
+			},
+			"/truncated": {
+				{Type: grawler.InformationalMessageType, Display: "This menu is cut short"},
+				{Type: grawler.InformationalMessageType, Display: "This menu is cut short"},
+				{Type: grawler.DirectoryType, Display: "Lost", Selector: "/lost"},
+			},
+		},
+		Failures: map[string]grawlertest.Failure{"/truncated": grawlertest.FailTruncate},
+		Delay:    10 * time.Millisecond,
+	},
+	"down":    {Down: true},
+	"garbage": {Failures: map[string]grawlertest.Failure{"": grawlertest.FailGarbage}},
+	"reset":   {Failures: map[string]grawlertest.Failure{"": grawlertest.FailReset}},
+	"hang":    {Failures: map[string]grawlertest.Failure{"": grawlertest.FailHang}},
+}
+
+// crawl crawls all menus reachable from root, one after another. It returns
+// the crawled menus and the errors by the names of the failed hosts.
+func crawl(space *grawlertest.Gopherspace, o grawler.ResourceOpener, root *grawler.Resource) ([]string, map[string]error) {
+	var crawled []string
+	errs := make(map[string]error)
+
+	coord := grawler.NewCoordinator()
+	coord.QueueJob(root)
+	for r := coord.QueuedJob(); r != nil; r = coord.QueuedJob() {
+		out := make(chan *grawler.CrawlFinding)
+		var err error
+		go func() {
+			err = grawler.ResourceCrawler(o, r, out)
+			close(out)
+		}()
+		for f := range out {
+			coord.QueueJob(f.Resource)
+		}
+		coord.FinishJob(r)
+
+		name := space.Name(r.Host)
+		crawled = append(crawled, name+r.Selector)
+		if err != nil {
+			errs[name] = err
+		}
+	}
+
+	sort.Strings(crawled)
+	return crawled, errs
+}
+
+func TestCrawlEndToEnd(t *testing.T) {
+	space, err := grawlertest.Start(e2eSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	c := space.Client()
+	c.Timeout = 200 * time.Millisecond
+	crawled, errs := crawl(space, grawler.ClientResourceOpener(c), space.Resource("alpha", ""))
+
+	expected := []string{"alpha", "alpha/self", "beta", "beta/truncated", "down", "down/again", "garbage", "hang", "reset"}
+	if len(crawled) != len(expected) {
+		t.Fatalf("%q != %q", crawled, expected)
+	}
+	for i := range crawled {
+		if crawled[i] != expected[i] {
+			t.Errorf("%q != %q", crawled[i], expected[i])
+		}
+	}
+
+	errTests := []struct {
+		host   string
+		target error
+		class  string
+	}{
+		{"down", grawler.ErrDial, grawler.ErrorClassRefused},
+		{"garbage", grawler.ErrMalformedLine, grawler.ErrorClassOther},
+		{"hang", grawler.ErrTimeout, grawler.ErrorClassTimeout},
+		{"reset", nil, grawler.ErrorClassReset},
+	}
+	for _, tt := range errTests {
+		err := errs[tt.host]
+		if tt.target != nil && !errors.Is(err, tt.target) {
+			t.Errorf("%s: %v is not %v", tt.host, err, tt.target)
+		}
+		if c := grawler.ErrorClass(err); c != tt.class {
+			t.Errorf("%s: %q != %q", tt.host, c, tt.class)
+		}
+	}
+	for _, h := range []string{"alpha", "beta"} {
+		if errs[h] != nil {
+			t.Errorf("%s: Unexpected error: %v", h, errs[h])
+		}
+	}
+
+	if r := space.Requests("alpha"); len(r) != 2 {
+		t.Errorf("%q != %q", r, []string{"", "/self"})
+	}
+}
+
+func TestBreakerEndToEnd(t *testing.T) {
+	space, err := grawlertest.Start(e2eSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	b := grawler.NewBreaker(1, time.Hour)
+	c := space.Client()
+	c.Timeout = 200 * time.Millisecond
+	_, errs := crawl(space, b.Opener(grawler.ClientResourceOpener(c)), space.Resource("alpha", ""))
+
+	if !errors.Is(errs["down"], grawler.ErrHostBroken) {
+		t.Errorf("%v is not %v", errs["down"], grawler.ErrHostBroken)
+	}
+	if s := b.State(space.Host("down")); s != grawler.BreakerOpen {
+		t.Errorf("%v != %v", s, grawler.BreakerOpen)
+	}
+}
@@ -0,0 +1,70 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Error classes returned by ErrorClass.
+const (
+	ErrorClassTimeout     = "timeout"
+	ErrorClassRefused     = "refused"
+	ErrorClassReset       = "reset"
+	ErrorClassUnreachable = "unreachable"
+	ErrorClassDNSNotFound = "dns_notfound"
+	ErrorClassDNS         = "dns"
+	ErrorClassTooLong     = "too_long"
+	ErrorClassBroken      = "broken"
+	ErrorClassTruncated   = "truncated"
+	ErrorClassOther       = "other"
+)
+
+// ErrorClass classifies err into a small set of classes suitable for
+// statistics, e.g. as metric label. An empty string is returned for a nil err.
+func ErrorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrHostBroken) {
+		return ErrorClassBroken
+	}
+	var truncErr *TruncatedError
+	if errors.As(err, &truncErr) {
+		return ErrorClassTruncated
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsNotFound {
+			return ErrorClassDNSNotFound
+		}
+		if dnsErr.IsTimeout {
+			return ErrorClassTimeout
+		}
+		return ErrorClassDNS
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ErrorClassReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return ErrorClassUnreachable
+	case errors.Is(err, bufio.ErrTooLong):
+		return ErrorClassTooLong
+	}
+
+	return ErrorClassOther
+}
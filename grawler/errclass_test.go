@@ -0,0 +1,36 @@
+package grawler
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+var errorClassTests = []struct {
+	err      error
+	expected string
+}{
+	{nil, ""},
+	{&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, ErrorClassDNSNotFound},
+	{&net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}, ErrorClassDNS},
+	{&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}, ErrorClassTimeout},
+	{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ErrorClassRefused},
+	{&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, ErrorClassReset},
+	{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}, ErrorClassUnreachable},
+	{&net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, ErrorClassTimeout},
+	{fmt.Errorf("reading menu: %w", bufio.ErrTooLong), ErrorClassTooLong},
+	{fmt.Errorf("%w: localhost:70", ErrHostBroken), ErrorClassBroken},
+	{fmt.Errorf("Resource is not a directory"), ErrorClassOther},
+}
+
+func TestErrorClass(t *testing.T) {
+	for _, tt := range errorClassTests {
+		c := ErrorClass(tt.err)
+		if c != tt.expected {
+			t.Errorf("%v: %q != %q", tt.err, c, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Errors returned by this package can be checked against these sentinels using
+// errors.Is. The structured error types below carry the details and can be
+// retrieved using errors.As.
+var (
+	// ErrNotDirectory is returned when crawling a Resource that is not of
+	// DirectoryType.
+	ErrNotDirectory = errors.New("Resource is not a directory")
+
+	// ErrMalformedLine matches a *MalformedLineError.
+	ErrMalformedLine = errors.New("Malformed Gopher line")
+
+	// ErrDial matches a *DialError.
+	ErrDial = errors.New("Could not connect")
+
+	// ErrTimeout matches a *DialError or *ReadError caused by a timeout.
+	ErrTimeout = errors.New("Timeout")
+
+	// ErrTruncated matches a *TruncatedError.
+	ErrTruncated = errors.New("Truncated")
+
+	// ErrDuplicateJob matches a *DuplicateJobError.
+	ErrDuplicateJob = errors.New("Duplicate job")
+
+	// ErrRejected matches a *RejectedError.
+	ErrRejected = errors.New("Rejected")
+)
+
+// isTimeout returns true, if err has been caused by a network timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// MalformedLineError is returned if a menu line could not be parsed.
+type MalformedLineError struct {
+	Line int // line number within the menu, 0 if unknown
+	Text string
+}
+
+func (e *MalformedLineError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("Could not parse Gopher line to resource: %q", e.Text)
+	}
+	return fmt.Sprintf("Could not parse Gopher line %d to resource: %q", e.Line, e.Text)
+}
+
+// Is reports whether target is ErrMalformedLine.
+func (e *MalformedLineError) Is(target error) bool {
+	return target == ErrMalformedLine
+}
+
+// DialError is returned by NetResourceOpener and ClientResourceOpener if the
+// connection to a Host could not be established.
+type DialError struct {
+	Host *Host
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("Could not connect to %v: %v", e.Host, e.Err)
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrDial, or ErrTimeout if the connection timed
+// out.
+func (e *DialError) Is(target error) bool {
+	return target == ErrDial || (target == ErrTimeout && isTimeout(e.Err))
+}
+
+// ReadError is returned by CrawlWithLimits if reading a menu failed.
+type ReadError struct {
+	Resource *Resource
+	Err      error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("Could not read %v: %v", e.Resource, e.Err)
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrTimeout and reading timed out.
+func (e *ReadError) Is(target error) bool {
+	return target == ErrTimeout && isTimeout(e.Err)
+}
+
+// Is reports whether target is ErrTruncated.
+func (e *TruncatedError) Is(target error) bool {
+	return target == ErrTruncated
+}
+
+// States of a job used by DuplicateJobError.
+const (
+	JobQueued   = "queued"
+	JobCrawling = "crawling"
+	JobCrawled  = "crawled"
+)
+
+// DuplicateJobError is returned by Coordinator.QueueJob if the job is already
+// known. This is generally not a real error condition.
+type DuplicateJobError struct {
+	Resource *Resource
+	State    string // one of the job states
+}
+
+func (e *DuplicateJobError) Error() string {
+	return fmt.Sprintf("Already %s %v", e.State, e.Resource)
+}
+
+// Is reports whether target is ErrDuplicateJob.
+func (e *DuplicateJobError) Is(target error) bool {
+	return target == ErrDuplicateJob
+}
+
+// Rules used by RejectedError.
+const (
+	RuleBlacklist = "blacklist"
+)
+
+// RejectedError is returned if a Resource must not be crawled because of a
+// rule.
+type RejectedError struct {
+	Resource *Resource
+	Rule     string // one of the rules
+	Pattern  string // the pattern of the rule that matched
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("Rejected %v: %s %q", e.Resource, e.Rule, e.Pattern)
+}
+
+// Is reports whether target is ErrRejected.
+func (e *RejectedError) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Blacklist is a list of selector substrings. Resources whose selector
+// contains one of them are rejected.
+type Blacklist []string
+
+// Check returns a *RejectedError, if the selector of Resource r contains one of
+// the substrings of the Blacklist.
+func (b Blacklist) Check(r *Resource) error {
+	for _, p := range b {
+		if strings.Contains(r.Selector, p) {
+			return &RejectedError{r, RuleBlacklist, p}
+		}
+	}
+	return nil
+}
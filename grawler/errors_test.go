@@ -0,0 +1,127 @@
+package grawler
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+var (
+	testHost = &Host{"localhost", "70"}
+	testMenu = &Resource{testHost, DirectoryType, "/"}
+)
+
+var errorsIsTests = []struct {
+	err      error
+	target   error
+	expected bool
+}{
+	{&MalformedLineError{3, "foo"}, ErrMalformedLine, true},
+	{&MalformedLineError{3, "foo"}, ErrDial, false},
+	{&DialError{testHost, errRefused}, ErrDial, true},
+	{&DialError{testHost, errRefused}, ErrTimeout, false},
+	{&DialError{testHost, errTimeout}, ErrTimeout, true},
+	{&ReadError{testMenu, errTimeout}, ErrTimeout, true},
+	{&ReadError{testMenu, errReset}, ErrTimeout, false},
+	{&TruncatedError{testMenu, LimitItems, 5}, ErrTruncated, true},
+	{&DuplicateJobError{testMenu, JobCrawled}, ErrDuplicateJob, true},
+	{&RejectedError{testMenu, RuleBlacklist, ".cgi?"}, ErrRejected, true},
+	{&RejectedError{testMenu, RuleBlacklist, ".cgi?"}, ErrDuplicateJob, false},
+}
+
+func TestErrorsIs(t *testing.T) {
+	for _, tt := range errorsIsTests {
+		if errors.Is(tt.err, tt.target) != tt.expected {
+			t.Errorf("%v, %v: %v != %v", tt.err, tt.target, !tt.expected, tt.expected)
+		}
+	}
+}
+
+var errorStringTests = []struct {
+	err      error
+	expected string
+}{
+	{&MalformedLineError{0, "foo"}, `Could not parse Gopher line to resource: "foo"`},
+	{&MalformedLineError{3, "foo"}, `Could not parse Gopher line 3 to resource: "foo"`},
+	{&DuplicateJobError{testMenu, JobQueued}, "Already queued gopher://localhost:70/1"},
+	{&RejectedError{testMenu, RuleBlacklist, ".cgi?"}, `Rejected gopher://localhost:70/1: blacklist ".cgi?"`},
+}
+
+func TestErrorString(t *testing.T) {
+	for _, tt := range errorStringTests {
+		if tt.err.Error() != tt.expected {
+			t.Errorf("%q != %q", tt.err.Error(), tt.expected)
+		}
+	}
+}
+
+func TestResourceCrawlerNotDirectory(t *testing.T) {
+	r := &Resource{testHost, '0', "/"}
+	err := ResourceCrawler(mockResourceOpener, r, nil)
+	if !errors.Is(err, ErrNotDirectory) {
+		t.Errorf("%v is not ErrNotDirectory", err)
+	}
+}
+
+func TestResourceCrawlerMalformedLine(t *testing.T) {
+	o := func(r *Resource) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("iinfo\t\tlocalhost\t70\r\nfoo\r\n.\r\n")), nil
+	}
+	err := ResourceCrawler(o, testMenu, nil)
+
+	var lineErr *MalformedLineError
+	if !errors.As(err, &lineErr) {
+		t.Fatalf("%v is not a *MalformedLineError", err)
+	}
+	if lineErr.Line != 2 {
+		t.Errorf("%d != %d", lineErr.Line, 2)
+	}
+	if lineErr.Text != "foo" {
+		t.Errorf("%q != %q", lineErr.Text, "foo")
+	}
+}
+
+func TestCoordinatorQueueJobDuplicate(t *testing.T) {
+	c := NewCoordinator()
+	if err := c.QueueJob(testMenu); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := c.QueueJob(testMenu)
+	var dupErr *DuplicateJobError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("%v is not a *DuplicateJobError", err)
+	}
+	if dupErr.State != JobQueued {
+		t.Errorf("%q != %q", dupErr.State, JobQueued)
+	}
+}
+
+var blacklistTests = []struct {
+	selector string
+	expected string // matching pattern, empty if not rejected
+}{
+	{"/", ""},
+	{"/game.cgi?x", ".cgi?"},
+	{"/bin/foo.run*", ".run*"},
+	{"/game.cgi", ""},
+}
+
+func TestBlacklistCheck(t *testing.T) {
+	b := Blacklist{".run*", ".cgi?"}
+	for _, tt := range blacklistTests {
+		err := b.Check(&Resource{testHost, DirectoryType, tt.selector})
+
+		var rejErr *RejectedError
+		switch {
+		case tt.expected == "" && err != nil:
+			t.Errorf("%q: Unexpected error: %v", tt.selector, err)
+		case tt.expected == "":
+		case !errors.As(err, &rejErr):
+			t.Errorf("%q: %v is not a *RejectedError", tt.selector, err)
+		case rejErr.Pattern != tt.expected:
+			t.Errorf("%q != %q", rejErr.Pattern, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+// Colors used by StyleByStatus.
+const (
+	AliveColor     = "#000000ff"
+	AliveFillColor = "#0000ff00"
+	DeadColor      = "#ff0000"
+	DeadFillColor  = "#ff00007f"
+)
+
+// GraphFilter implements a post-processing step modifying a Graph in place.
+type GraphFilter func(g *Graph)
+
+// Filter applies the GraphFilters in order to Graph g.
+func (g *Graph) Filter(filters ...GraphFilter) {
+	for _, f := range filters {
+		f(g)
+	}
+}
+
+// RemoveNode removes node n and all edges from or to it from Graph g.
+func (g *Graph) RemoveNode(n string) {
+	delete(g.Nodes, n)
+	for e := range g.Edges {
+		if e.From == n || e.To == n {
+			delete(g.Edges, e)
+		}
+	}
+}
+
+// KeepAlive is a GraphFilter removing all nodes that are not alive, and the
+// edges from or to them.
+func KeepAlive(g *Graph) {
+	for n := range g.Nodes {
+		if !g.Alive(n) {
+			delete(g.Nodes, n)
+		}
+	}
+	for e := range g.Edges {
+		if _, ok := g.Nodes[e.From]; !ok {
+			delete(g.Edges, e)
+			continue
+		}
+		if _, ok := g.Nodes[e.To]; !ok {
+			delete(g.Edges, e)
+		}
+	}
+}
+
+// DropSelfLoops is a GraphFilter removing all edges from a node to itself.
+func DropSelfLoops(g *Graph) {
+	for e := range g.Edges {
+		if e.From == e.To {
+			delete(g.Edges, e)
+		}
+	}
+}
+
+// DropDeadEdges is a GraphFilter removing all edges leading to nodes that are
+// not alive. Nodes are kept, even if they are not part of any edge afterwards.
+func DropDeadEdges(g *Graph) {
+	for e := range g.Edges {
+		if !g.Alive(e.To) {
+			delete(g.Edges, e)
+		}
+	}
+}
+
+// StyleByStatus is a GraphFilter coloring nodes that are not alive and the
+// edges leading to or coming from them red.
+func StyleByStatus(g *Graph) {
+	for n, a := range g.Nodes {
+		a["style"] = "filled"
+		if g.Alive(n) {
+			a["color"] = AliveColor
+			a["fillcolor"] = AliveFillColor
+		} else {
+			a["color"] = DeadColor
+			a["fillcolor"] = DeadFillColor
+		}
+	}
+	for e, a := range g.Edges {
+		if g.Alive(e.From) && g.Alive(e.To) {
+			a["color"] = AliveColor
+		} else {
+			a["color"] = DeadColor
+		}
+	}
+}
+
+// Neighbourhood returns a GraphFilter removing all nodes that are more than k
+// hops away from node n. Edges are followed in both directions. If n is not
+// part of the Graph, all nodes are removed.
+func Neighbourhood(n string, k int) GraphFilter {
+	return func(g *Graph) {
+		adjacent := make(map[string][]string)
+		for e := range g.Edges {
+			adjacent[e.From] = append(adjacent[e.From], e.To)
+			adjacent[e.To] = append(adjacent[e.To], e.From)
+		}
+
+		keep := make(map[string]bool)
+		if _, ok := g.Nodes[n]; ok {
+			keep[n] = true
+		}
+		frontier := []string{n}
+		for hop := 0; hop < k && len(frontier) > 0; hop++ {
+			var next []string
+			for _, f := range frontier {
+				for _, a := range adjacent[f] {
+					if !keep[a] {
+						keep[a] = true
+						next = append(next, a)
+					}
+				}
+			}
+			frontier = next
+		}
+
+		for node := range g.Nodes {
+			if !keep[node] {
+				delete(g.Nodes, node)
+			}
+		}
+		for e := range g.Edges {
+			if !keep[e.From] || !keep[e.To] {
+				delete(g.Edges, e)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package grawler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var filterDotfile = `strict digraph {
+	"a:70"[alive=true]
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"a:70" -> "a:70"
+	"a:70" -> "b:70"
+	"a:70" -> "dead:70"
+	"b:70" -> "c:70"
+	"c:70" -> "d:70"
+	"d:70"[alive=true]
+	"d:70" -> "e:70"
+}
+`
+
+var filterTests = []struct {
+	name     string
+	filters  []GraphFilter
+	expected string
+}{
+	{"none", nil, `strict digraph {
+	"a:70"[alive=true]
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"d:70"[alive=true]
+	"a:70" -> "a:70"
+	"a:70" -> "b:70"
+	"a:70" -> "dead:70"
+	"b:70" -> "c:70"
+	"c:70" -> "d:70"
+	"d:70" -> "e:70"
+}
+`},
+	{"keep alive", []GraphFilter{KeepAlive}, `strict digraph {
+	"a:70"[alive=true]
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"d:70"[alive=true]
+	"a:70" -> "a:70"
+	"a:70" -> "b:70"
+	"b:70" -> "c:70"
+	"c:70" -> "d:70"
+}
+`},
+	{"drop self-loops", []GraphFilter{DropSelfLoops, DropDeadEdges}, `strict digraph {
+	"a:70"[alive=true]
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"d:70"[alive=true]
+	"dead:70"
+	"e:70"
+	"a:70" -> "b:70"
+	"b:70" -> "c:70"
+	"c:70" -> "d:70"
+}
+`},
+	{"neighbourhood", []GraphFilter{Neighbourhood("c:70", 1)}, `strict digraph {
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"d:70"[alive=true]
+	"b:70" -> "c:70"
+	"c:70" -> "d:70"
+}
+`},
+	{"unknown neighbourhood", []GraphFilter{Neighbourhood("x:70", 3)}, `strict digraph {
+}
+`},
+	{"style", []GraphFilter{KeepAlive, Neighbourhood("a:70", 1), StyleByStatus}, `strict digraph {
+	"a:70"[alive=true,color="#000000ff",fillcolor="#0000ff00",style=filled]
+	"b:70"[alive=true,color="#000000ff",fillcolor="#0000ff00",style=filled]
+	"a:70" -> "a:70"[color="#000000ff"]
+	"a:70" -> "b:70"[color="#000000ff"]
+}
+`},
+}
+
+func TestGraphFilter(t *testing.T) {
+	for _, tt := range filterTests {
+		g, err := ReadGraph(strings.NewReader(filterDotfile))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		g.Filter(tt.filters...)
+
+		var b bytes.Buffer
+		if _, err := g.WriteTo(&b); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if b.String() != tt.expected {
+			t.Errorf("%s: %q != %q", tt.name, b.String(), tt.expected)
+		}
+	}
+}
+
+func TestStyleByStatusDead(t *testing.T) {
+	g, err := ReadGraph(strings.NewReader(filterDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	g.Filter(StyleByStatus)
+
+	if c := g.Nodes["dead:70"]["fillcolor"]; c != DeadFillColor {
+		t.Errorf("%q != %q", c, DeadFillColor)
+	}
+	if c := g.Edges[Edge{"a:70", "dead:70"}]["color"]; c != DeadColor {
+		t.Errorf("%q != %q", c, DeadColor)
+	}
+}
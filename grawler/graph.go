@@ -0,0 +1,414 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Attributes are the dot attributes of a node or an edge.
+type Attributes map[string]string
+
+// String returns the dot representation of the attributes, e.g.
+// `[alive=true]`. The keys are sorted to get a stable output. An empty string
+// is returned if there are no attributes.
+func (a Attributes) String() string {
+	if len(a) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%s", k, dotValue(a[k]))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// dotBareID matches the dot IDs that do not have to be quoted: identifiers
+// and numerals. Exponents like in "9.87e-05" are not part of the dot language.
+var dotBareID = regexp.MustCompile(`^([A-Za-z_][A-Za-z_0-9]*|-?(\.[0-9]+|[0-9]+(\.[0-9]*)?))$`)
+
+// dotValue quotes v if it can not be used as a bare dot ID.
+func dotValue(v string) string {
+	if !dotBareID.MatchString(v) {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// Edge is a directed edge between two nodes of a Graph.
+type Edge struct {
+	From string
+	To   string
+}
+
+// Graph is an in-memory representation of the server relations written by
+// Grapher. Nodes are identified by their Host string.
+type Graph struct {
+	Nodes map[string]Attributes
+	Edges map[Edge]Attributes
+}
+
+// NewGraph creates and initializes a new, empty Graph.
+func NewGraph() *Graph {
+	return &Graph{
+		Nodes: make(map[string]Attributes),
+		Edges: make(map[Edge]Attributes),
+	}
+}
+
+// AddNode adds node n to the Graph, if it is not already known, and merges
+// attributes a into the attributes of the node.
+func (g *Graph) AddNode(n string, a Attributes) {
+	attrs, ok := g.Nodes[n]
+	if !ok {
+		attrs = make(Attributes)
+		g.Nodes[n] = attrs
+	}
+	for k, v := range a {
+		attrs[k] = v
+	}
+}
+
+// AddEdge adds an edge from node from to node to, if it is not already known,
+// and merges attributes a into the attributes of the edge. Both nodes are added
+// to the Graph as well.
+func (g *Graph) AddEdge(from, to string, a Attributes) {
+	g.AddNode(from, nil)
+	g.AddNode(to, nil)
+
+	e := Edge{from, to}
+	attrs, ok := g.Edges[e]
+	if !ok {
+		attrs = make(Attributes)
+		g.Edges[e] = attrs
+	}
+	for k, v := range a {
+		attrs[k] = v
+	}
+}
+
+// Alive returns true, if node n has been marked alive by Grapher.
+func (g *Graph) Alive(n string) bool {
+	return g.Nodes[n]["alive"] == "true"
+}
+
+// Reference returns true, if Edge e of the Graph is a reference of one node to
+// another. Self-loops and the sameAs edges added by MarkAliases are not.
+func (g *Graph) Reference(e Edge) bool {
+	return e.From != e.To && g.Edges[e]["sameAs"] != "true"
+}
+
+// InDegrees returns the in-degree of every node, i.e. the number of distinct
+// other nodes referencing it. Only references are counted.
+func (g *Graph) InDegrees() map[string]int {
+	d := make(map[string]int, len(g.Nodes))
+	for n := range g.Nodes {
+		d[n] = 0
+	}
+	for e := range g.Edges {
+		if g.Reference(e) {
+			d[e.To]++
+		}
+	}
+	return d
+}
+
+// OutDegrees returns the out-degree of every node, i.e. the number of distinct
+// other nodes it references. Only references are counted.
+func (g *Graph) OutDegrees() map[string]int {
+	d := make(map[string]int, len(g.Nodes))
+	for n := range g.Nodes {
+		d[n] = 0
+	}
+	for e := range g.Edges {
+		if g.Reference(e) {
+			d[e.From]++
+		}
+	}
+	return d
+}
+
+// Hosts returns the Hosts of all nodes in lexical order. Nodes that can not be
+// parsed as Host are skipped.
+func (g *Graph) Hosts() []*Host {
+	var hosts []*Host
+	for _, n := range g.SortedNodes() {
+		h, err := ParseHost(n)
+		if err != nil {
+			continue
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// SortedNodes returns the names of all nodes in lexical order.
+func (g *Graph) SortedNodes() []string {
+	nodes := make([]string, 0, len(g.Nodes))
+	for n := range g.Nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// SortedEdges returns all edges ordered by their From and To nodes.
+func (g *Graph) SortedEdges() []Edge {
+	edges := make([]Edge, 0, len(g.Edges))
+	for e := range g.Edges {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// WriteTo writes the Graph as dotfile to w. Nodes and edges are sorted, so the
+// output is stable. Nodes without attributes are only written if they are not
+// part of any edge.
+func (g *Graph) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	write := func(s string) error {
+		m, err := io.WriteString(w, s)
+		n += int64(m)
+		return err
+	}
+
+	if err := write("strict digraph {\n"); err != nil {
+		return n, err
+	}
+
+	connected := make(map[string]bool)
+	for e := range g.Edges {
+		connected[e.From] = true
+		connected[e.To] = true
+	}
+
+	for _, node := range g.SortedNodes() {
+		a := g.Nodes[node]
+		if len(a) == 0 && connected[node] {
+			continue
+		}
+		if err := write(fmt.Sprintf("\t%q%s\n", node, a)); err != nil {
+			return n, err
+		}
+	}
+
+	for _, e := range g.SortedEdges() {
+		s := fmt.Sprintf("\t%q -> %q%s\n", e.From, e.To, g.Edges[e])
+		if err := write(s); err != nil {
+			return n, err
+		}
+	}
+
+	err := write("}\n")
+	return n, err
+}
+
+// ReadGraph reads a dotfile as written by Grapher or Graph.WriteTo. Only the
+// subset of the dot language used by these writers is supported: one node or
+// edge statement per line, with optional attribute lists.
+func ReadGraph(r io.Reader) (*Graph, error) {
+	g := NewGraph()
+
+	scan := bufio.NewScanner(r)
+	lineno := 0
+	for scan.Scan() {
+		lineno++
+
+		line := strings.TrimSpace(scan.Text())
+		line = strings.TrimSuffix(line, ";")
+		switch {
+		case line == "", line == "}", strings.HasSuffix(line, "{"):
+			continue
+		}
+
+		err := parseStatement(g, line)
+		if err != nil {
+			return nil, fmt.Errorf("Could not parse dotfile line %d: %v", lineno, err)
+		}
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// parseStatement parses a single node or edge statement and adds it to g.
+func parseStatement(g *Graph, s string) error {
+	from, rest, err := parseID(s)
+	if err != nil {
+		return err
+	}
+
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "->") {
+		to, rest, err := parseID(strings.TrimSpace(rest[2:]))
+		if err != nil {
+			return err
+		}
+		a, err := parseAttributes(strings.TrimSpace(rest))
+		if err != nil {
+			return err
+		}
+		g.AddEdge(from, to, a)
+		return nil
+	}
+
+	a, err := parseAttributes(rest)
+	if err != nil {
+		return err
+	}
+	g.AddNode(from, a)
+	return nil
+}
+
+// parseID parses a quoted or bare dot ID at the beginning of s. It returns the
+// ID and the remaining string.
+func parseID(s string) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing ID")
+	}
+
+	if s[0] == '"' {
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				id, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					// Grapher does not escape IDs, so take them
+					// literally.
+					id = s[1:i]
+				}
+				return id, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string: %s", s)
+	}
+
+	i := strings.IndexAny(s, " \t[=,]")
+	if i == 0 {
+		return "", "", fmt.Errorf("missing ID: %s", s)
+	}
+	if i < 0 {
+		return s, "", nil
+	}
+	return s[:i], s[i:], nil
+}
+
+// parseAttributes parses an attribute list like `[alive=true, color="red"]`.
+// An empty string yields nil Attributes.
+func parseAttributes(s string) (Attributes, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '[' || s[len(s)-1] != ']' {
+		return nil, fmt.Errorf("malformed attribute list: %s", s)
+	}
+
+	a := make(Attributes)
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	for s != "" {
+		k, rest, err := parseID(s)
+		if err != nil {
+			return nil, err
+		}
+		rest = strings.TrimSpace(rest)
+		if !strings.HasPrefix(rest, "=") {
+			return nil, fmt.Errorf("missing value for attribute %q", k)
+		}
+		v, rest, err := parseID(strings.TrimSpace(rest[1:]))
+		if err != nil {
+			return nil, err
+		}
+		a[k] = v
+
+		s = strings.TrimLeft(rest, " \t,;")
+	}
+	return a, nil
+}
+
+// GraphDiff describes the differences between two Graphs, e.g. the results of
+// two crawls.
+type GraphDiff struct {
+	Appeared     []string // nodes only found in the new Graph
+	Disappeared  []string // nodes only found in the old Graph
+	Died         []string // nodes alive in the old Graph, but not in the new one
+	Revived      []string // nodes not alive in the old Graph, but in the new one
+	AddedEdges   []Edge   // edges only found in the new Graph
+	RemovedEdges []Edge   // edges only found in the old Graph
+}
+
+// Diff returns the differences between Graph old and Graph g. All lists are
+// sorted and never nil.
+func (g *Graph) Diff(old *Graph) *GraphDiff {
+	d := &GraphDiff{
+		Appeared:     []string{},
+		Disappeared:  []string{},
+		Died:         []string{},
+		Revived:      []string{},
+		AddedEdges:   []Edge{},
+		RemovedEdges: []Edge{},
+	}
+
+	for _, n := range g.SortedNodes() {
+		if _, ok := old.Nodes[n]; !ok {
+			d.Appeared = append(d.Appeared, n)
+			continue
+		}
+
+		switch {
+		case old.Alive(n) && !g.Alive(n):
+			d.Died = append(d.Died, n)
+		case !old.Alive(n) && g.Alive(n):
+			d.Revived = append(d.Revived, n)
+		}
+	}
+	for _, n := range old.SortedNodes() {
+		if _, ok := g.Nodes[n]; !ok {
+			d.Disappeared = append(d.Disappeared, n)
+		}
+	}
+
+	for _, e := range g.SortedEdges() {
+		if _, ok := old.Edges[e]; !ok {
+			d.AddedEdges = append(d.AddedEdges, e)
+		}
+	}
+	for _, e := range old.SortedEdges() {
+		if _, ok := g.Edges[e]; !ok {
+			d.RemovedEdges = append(d.RemovedEdges, e)
+		}
+	}
+
+	return d
+}
@@ -0,0 +1,228 @@
+package grawler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var attributesStringTests = []struct {
+	attributes Attributes
+	expected   string
+}{
+	{nil, ""},
+	{Attributes{"alive": "true"}, "[alive=true]"},
+	{Attributes{"sameAs": "true", "alive": "true"}, "[alive=true,sameAs=true]"},
+	{Attributes{"aliases": "a:70 b:70"}, `[aliases="a:70 b:70"]`},
+	{Attributes{"label": ""}, `[label=""]`},
+	{Attributes{"pagerank": "0.25"}, "[pagerank=0.25]"},
+	{Attributes{"pagerank": "-.5"}, "[pagerank=-.5]"},
+	{Attributes{"pagerank": "9.87e-05"}, `[pagerank="9.87e-05"]`},
+	{Attributes{"pagerank": "1e+06"}, `[pagerank="1e+06"]`},
+	{Attributes{"scc": "-1"}, "[scc=-1]"},
+	{Attributes{"label": "1.2.3"}, `[label="1.2.3"]`},
+	{Attributes{"label": "-"}, `[label="-"]`},
+	{Attributes{"label": "2abc"}, `[label="2abc"]`},
+	{Attributes{"error": "dns_notfound"}, "[error=dns_notfound]"},
+}
+
+func TestAttributesString(t *testing.T) {
+	for _, tt := range attributesStringTests {
+		s := tt.attributes.String()
+		if s != tt.expected {
+			t.Errorf("%q != %q", s, tt.expected)
+		}
+	}
+}
+
+func TestReadGraph(t *testing.T) {
+	g, err := ReadGraph(strings.NewReader(nonEmptyDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedNodes := []string{
+		"gopher.example.com:72",
+		"localhost:70",
+		"parent:70",
+		"referenced:72",
+	}
+	nodes := g.SortedNodes()
+	if strings.Join(nodes, " ") != strings.Join(expectedNodes, " ") {
+		t.Errorf("%q != %q", nodes, expectedNodes)
+	}
+
+	for n, alive := range map[string]bool{
+		"parent:70":             true,
+		"gopher.example.com:72": true,
+		"referenced:72":         false,
+		"localhost:70":          false,
+	} {
+		if g.Alive(n) != alive {
+			t.Errorf("Unexpected alive state of %q: %v", n, g.Alive(n))
+		}
+	}
+
+	expectedEdges := []Edge{
+		{"gopher.example.com:72", "gopher.example.com:72"},
+		{"gopher.example.com:72", "localhost:70"},
+		{"parent:70", "referenced:72"},
+	}
+	edges := g.SortedEdges()
+	if len(edges) != len(expectedEdges) {
+		t.Fatalf("Unexpected edges: %v", edges)
+	}
+	for i, e := range expectedEdges {
+		if edges[i] != e {
+			t.Errorf("%v != %v", edges[i], e)
+		}
+	}
+}
+
+var malformedDotfiles = []string{
+	"strict digraph {\n\t\"unterminated\n}\n",
+	"strict digraph {\n\t\"a\" -> \n}\n",
+	"strict digraph {\n\t\"a\"[alive]\n}\n",
+	"strict digraph {\n\t\"a\"[alive=true\n}\n",
+}
+
+func TestReadGraphMalformed(t *testing.T) {
+	for _, d := range malformedDotfiles {
+		_, err := ReadGraph(strings.NewReader(d))
+		if err == nil {
+			t.Errorf("Parsing %q succeeded unexpected", d)
+		}
+	}
+}
+
+func TestGraphWriteTo(t *testing.T) {
+	g := NewGraph()
+	g.AddEdge("b:70", "a:70", nil)
+	g.AddEdge("a:70", "b:70", Attributes{"sameAs": "true"})
+	g.AddNode("a:70", Attributes{"alive": "true"})
+	g.AddNode("lonely:70", nil)
+
+	expected := `strict digraph {
+	"a:70"[alive=true]
+	"lonely:70"
+	"a:70" -> "b:70"[sameAs=true]
+	"b:70" -> "a:70"
+}
+`
+
+	var b bytes.Buffer
+	_, err := g.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.String() != expected {
+		t.Fatalf("Unexpected dotfile content: %q != %q", b.String(), expected)
+	}
+
+	r, err := ReadGraph(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(r.Nodes) != 3 || len(r.Edges) != 2 {
+		t.Fatalf("Unexpected graph after round trip: %v", r)
+	}
+	if r.Edges[Edge{"a:70", "b:70"}]["sameAs"] != "true" {
+		t.Errorf("Edge attributes lost in round trip: %v", r.Edges)
+	}
+}
+
+func TestGraphInDegrees(t *testing.T) {
+	g, err := ReadGraph(strings.NewReader(nonEmptyDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	g.AddEdge("parent:70", "localhost:70", nil)
+	g.AddEdge("localhost:70", "alias:70", Attributes{"sameAs": "true"})
+
+	expected := map[string]int{
+		"alias:70":              0,
+		"parent:70":             0,
+		"referenced:72":         1,
+		"gopher.example.com:72": 0,
+		"localhost:70":          2,
+	}
+	d := g.InDegrees()
+	if len(d) != len(expected) {
+		t.Fatalf("Unexpected in-degrees: %v", d)
+	}
+	for n, e := range expected {
+		if d[n] != e {
+			t.Errorf("In-degree of %q: %d != %d", n, d[n], e)
+		}
+	}
+}
+
+func TestGraphOutDegrees(t *testing.T) {
+	g, err := ReadGraph(strings.NewReader(nonEmptyDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	g.AddEdge("parent:70", "localhost:70", nil)
+	g.AddEdge("localhost:70", "alias:70", Attributes{"sameAs": "true"})
+
+	expected := map[string]int{
+		"alias:70":              0,
+		"parent:70":             2,
+		"referenced:72":         0,
+		"gopher.example.com:72": 1,
+		"localhost:70":          0,
+	}
+	d := g.OutDegrees()
+	if len(d) != len(expected) {
+		t.Fatalf("Unexpected out-degrees: %v", d)
+	}
+	for n, e := range expected {
+		if d[n] != e {
+			t.Errorf("Out-degree of %q: %d != %d", n, d[n], e)
+		}
+	}
+}
+
+func TestGraphDiff(t *testing.T) {
+	old := NewGraph()
+	old.AddNode("alive:70", Attributes{"alive": "true"})
+	old.AddNode("dies:70", Attributes{"alive": "true"})
+	old.AddNode("vanishes:70", Attributes{"alive": "true"})
+	old.AddEdge("alive:70", "dies:70", nil)
+	old.AddEdge("alive:70", "revives:70", nil)
+	old.AddEdge("dies:70", "vanishes:70", nil)
+
+	g := NewGraph()
+	g.AddNode("alive:70", Attributes{"alive": "true"})
+	g.AddNode("revives:70", Attributes{"alive": "true"})
+	g.AddEdge("alive:70", "dies:70", nil)
+	g.AddEdge("alive:70", "revives:70", nil)
+	g.AddEdge("revives:70", "new:70", nil)
+
+	d := g.Diff(old)
+
+	expected := []struct {
+		name     string
+		got      interface{}
+		expected interface{}
+	}{
+		{"appeared", d.Appeared, []string{"new:70"}},
+		{"disappeared", d.Disappeared, []string{"vanishes:70"}},
+		{"died", d.Died, []string{"dies:70"}},
+		{"revived", d.Revived, []string{"revives:70"}},
+		{"added edges", d.AddedEdges, []Edge{{"revives:70", "new:70"}}},
+		{"removed edges", d.RemovedEdges, []Edge{{"dies:70", "vanishes:70"}}},
+	}
+	for _, e := range expected {
+		if fmt.Sprint(e.got) != fmt.Sprint(e.expected) {
+			t.Errorf("Unexpected %s: %v != %v", e.name, e.got, e.expected)
+		}
+	}
+
+	d = g.Diff(g)
+	if len(d.Appeared)+len(d.Disappeared)+len(d.Died)+len(d.Revived)+
+		len(d.AddedEdges)+len(d.RemovedEdges) != 0 {
+		t.Errorf("Unexpected differences of identical graphs: %#v", d)
+	}
+}
@@ -0,0 +1,745 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Building blocks for the grawler gopherspace crawler.
+package grawler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabber/grawler/gopher"
+)
+
+// ItemType is the type of an item in a gopher menu.
+type ItemType byte
+
+// DirectoryType is the ItemType to describe a directory.
+const DirectoryType ItemType = '1'
+const InformationalMessageType ItemType = 'i'
+const ErrorMessageType ItemType = '3'
+
+// String returns the string representation of the ItemType
+func (t ItemType) String() string {
+	return string(t)
+}
+
+// itemTypeNames maps the ItemTypes of RFC 1436 and the common extensions to
+// their names.
+var itemTypeNames = map[ItemType]string{
+	'0': "text",
+	'1': "directory",
+	'2': "cso",
+	'3': "error",
+	'4': "binhex",
+	'5': "dos",
+	'6': "uuencoded",
+	'7': "search",
+	'8': "telnet",
+	'9': "binary",
+	'+': "mirror",
+	'g': "gif",
+	'I': "image",
+	'T': "tn3270",
+	'h': "html",
+	'i': "info",
+	's': "sound",
+	'd': "document",
+	'p': "png",
+	':': "bitmap",
+	';': "movie",
+	'<': "sound",
+}
+
+// Name returns a human readable name of the ItemType, e.g. "directory". Unknown
+// ItemTypes are named "unknown".
+func (t ItemType) Name() string {
+	if n, ok := itemTypeNames[t]; ok {
+		return n
+	}
+	return "unknown"
+}
+
+// Host describes a gopher server.
+type Host struct {
+	Hostname string
+	Port     string
+}
+
+// String returns the string representation of a gopher server suitable for
+// net.Dial() calls. As the returned string is used as a key in maps all
+// characters are converted to lower case.
+func (h *Host) String() string {
+	return strings.ToLower(net.JoinHostPort(h.Hostname, h.Port))
+}
+
+// ParseHost parses the string representation of a gopher server as returned
+// by Host.String() and returns a corresponding *Host.
+func ParseHost(s string) (*Host, error) {
+	hostname, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Host{hostname, port}, nil
+}
+
+// Resource represents a gopher resource identified by a *Host, ItemType and
+// selector string.
+type Resource struct {
+	*Host
+	Type     ItemType
+	Selector string
+}
+
+// NewResourceFromGopherLine parses a gopher menu line an returns a
+// corresponding *Resource.
+func NewResourceFromGopherLine(line string) (*Resource, error) {
+	i, err := NewItemFromGopherLine(line)
+	if err != nil {
+		return nil, err
+	}
+
+	// Do not reference the Item, its display string references the whole
+	// menu line.
+	r := i.Resource
+	return &r, nil
+}
+
+// Item represents an item of a gopher menu, identified by the referenced
+// Resource and its display string.
+type Item struct {
+	Resource
+	Display string
+
+	// Plus is true if the item has been marked as Gopher+ item.
+	Plus bool
+
+	// Parent is the menu containing the item and Line the line number of
+	// the item in this menu, starting at 1. Parent is nil and Line is 0 if
+	// the item has not been read from a crawled menu.
+	Parent *Resource
+	Line   int
+}
+
+// NewItemFromGopherLine parses a gopher menu line an returns a corresponding
+// *Item.
+func NewItemFromGopherLine(line string) (*Item, error) {
+	i, err := gopher.ParseItem(line)
+	if err != nil {
+		return nil, &MalformedLineError{0, line}
+	}
+
+	return &Item{
+		Resource{
+			// Copy the strings, queued Resources must not
+			// reference the whole menu line.
+			&Host{string([]byte(i.Hostname)), string([]byte(i.Port))},
+			ItemType(i.Type),
+			string([]byte(i.Selector)),
+		},
+		i.Display,
+		i.Plus,
+		nil,
+		0,
+	}, nil
+}
+
+// String returns a URI string representation of a Resource. If the selector
+// string is "/" it is replaced by an empty string.
+// If for some reason the URI string could not be assembled an empty string is
+// returned.
+func (r *Resource) String() string {
+	s, _ := r.TryString()
+
+	return s
+}
+
+// TryString returns a URI string representation of a Resource. If the selector
+// string is "/" it is replaced by an empty string.
+// If for some reason the URI string could not be assembled an empty string and
+// a corresponding error is returned.
+func (r *Resource) TryString() (string, error) {
+	s := r.Selector
+	if s == "/" {
+		s = ""
+	}
+	u, err := url.Parse(fmt.Sprintf("gopher://%v/%v%s", r.Host, r.Type, s))
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+// CrawlFinding represents a reference to another Resource found by a crawler,
+// identified by the referenced Resource and the Parent Host referencing this
+// Resource.
+type CrawlFinding struct {
+	Resource *Resource
+	Parent   *Host
+}
+
+// String returns a string representation suitable for inclusion in a dot file.
+// It represents a directed edge between parent Host and referenced Host. The
+// selector string and item type are deliberately dropped.
+func (f *CrawlFinding) String() string {
+	if f.Parent == nil {
+		return fmt.Sprintf(`"%v"`, f.Resource.Host)
+	}
+
+	return fmt.Sprintf(`"%v" -> "%v"`, f.Parent, f.Resource.Host)
+}
+
+// ResourceOpener implements a way to open a Resource, returning a io.ReadCloser
+// that can be used to read the Resource. The caller is expexted to close the
+// returned io.ReadCloser.
+type ResourceOpener func(*Resource) (io.ReadCloser, error)
+
+// NetResourceOpener is a ResourceOpener that implements a way to open Resource
+// r via a network connection.
+//
+// Establishing a connection times out after five seconds. An established
+// connection times out after one minute.
+func NetResourceOpener(r *Resource) (io.ReadCloser, error) {
+	return netOpener(r)
+}
+
+// netOpener implements NetResourceOpener using a gopher.Client with the
+// default timeouts.
+var netOpener = ClientResourceOpener(&gopher.Client{})
+
+// ClientResourceOpener returns a ResourceOpener that opens resources using
+// gopher.Client c. Failures to connect are returned as *DialError.
+func ClientResourceOpener(c *gopher.Client) ResourceOpener {
+	return func(r *Resource) (io.ReadCloser, error) {
+		addr := net.JoinHostPort(r.Hostname, r.Port)
+		rc, err := c.Open(context.Background(), addr, r.Selector)
+		var gerr *gopher.Error
+		if errors.As(err, &gerr) && gerr.Op == "dial" {
+			return nil, &DialError{r.Host, gerr.Err}
+		}
+		return rc, err
+	}
+}
+
+// SkipMenu is used as a return value from ItemActionFuncs to indicate that the
+// remaining items of the menu should not be processed. It is not returned as an
+// error by any function.
+var SkipMenu = errors.New("skip the remaining items of this menu")
+
+// ItemActionFunc is a function that can be called by ResourceCrawler for items
+// that are neither informational texts nor error messages.
+//
+// If an ItemActionFunc returns an error, ResourceCrawler stops processing the
+// menu immediately. The special value SkipMenu makes ResourceCrawler return
+// without error, any other error is returned by ResourceCrawler.
+type ItemActionFunc func(Item) error
+
+// ResourceCrawler crawls a gopher menz. It uses ResourceOpener o to get access
+// to a a Resource r that is expected to be of type DirectoryType. It then looks
+// for references to other directories and reports its findings via the out
+// channel.
+//
+// If ItemAction are passed, they are called for every Item in the directory
+// that is not a InformationalMessageType or ErrorMessageType. The Parent of the
+// Items passed is r.
+//
+// ResourceCrawler uses the default Limits, see CrawlWithLimits.
+func ResourceCrawler(o ResourceOpener, r *Resource, out chan<- *CrawlFinding, ia ...ItemActionFunc) error {
+	return CrawlWithLimits(o, r, Limits{}, out, ia...)
+}
+
+// DefaultMaxLineLength is the maximum length of a menu line used if
+// Limits.MaxLineLength is not set.
+const DefaultMaxLineLength = gopher.DefaultMaxLineLength
+
+// Limits restrict the resources read by CrawlWithLimits. Zero values mean no
+// limit, except for MaxLineLength which defaults to DefaultMaxLineLength.
+type Limits struct {
+	MaxBytes      int64 // maximum number of bytes read from a menu
+	MaxLineLength int   // maximum length of a menu line, without line ending
+	MaxItems      int   // maximum number of lines of a menu
+}
+
+// Limit names used by TruncatedError.
+const (
+	LimitBytes      = "bytes"
+	LimitLineLength = "line_length"
+	LimitItems      = "items"
+)
+
+// TruncatedError is returned by CrawlWithLimits if a menu has been truncated
+// because one of its Limits has been reached. All lines read before have been
+// processed.
+type TruncatedError struct {
+	Resource *Resource
+	Limit    string // one of the Limit names
+	Lines    int    // number of lines processed
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("Truncated %v after %d lines: %s limit reached", e.Resource,
+		e.Lines, e.Limit)
+}
+
+// errBytesLimit is returned by bytesLimitReader if the limit has been reached.
+var errBytesLimit = errors.New("bytes limit reached")
+
+// bytesLimitReader reads at most n bytes from r. Reading more returns
+// errBytesLimit, unless r is exhausted.
+type bytesLimitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *bytesLimitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n == 0 && err != nil {
+			return 0, err
+		}
+		return 0, errBytesLimit
+	}
+
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// CrawlWithLimits works like ResourceCrawler, but reads the menu within Limits
+// l. If a limit is reached, reading stops and a *TruncatedError is returned.
+// The lines read up to that point have been processed.
+func CrawlWithLimits(o ResourceOpener, r *Resource, l Limits, out chan<- *CrawlFinding, ia ...ItemActionFunc) error {
+	if r.Type != DirectoryType {
+		return fmt.Errorf("%w: %v", ErrNotDirectory, r)
+	}
+
+	rc, err := o(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var in io.Reader = rc
+	if l.MaxBytes > 0 {
+		in = &bytesLimitReader{rc, l.MaxBytes}
+	}
+
+	scan := gopher.NewMenuScanner(in, l.MaxLineLength)
+	line := 0
+	for scan.Scan() {
+		if l.MaxItems > 0 && line >= l.MaxItems {
+			return &TruncatedError{r, LimitItems, line}
+		}
+		line++
+
+		item, err := NewItemFromGopherLine(scan.Text())
+		if err != nil {
+			var lineErr *MalformedLineError
+			if errors.As(err, &lineErr) {
+				lineErr.Line = line
+			}
+			return err
+		}
+		item.Parent = r
+		item.Line = line
+		res := &item.Resource
+
+		if res.Type != InformationalMessageType && res.Type != ErrorMessageType {
+			for _, a := range ia {
+				err := a(*item)
+				if err == SkipMenu {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if res.Type == DirectoryType {
+			// Yep, it is a directory item. Copy the Resource, the
+			// Item references the whole menu line.
+			res := *res
+			f := &CrawlFinding{&res, r.Host}
+			out <- f
+		}
+	}
+	switch err = scan.Err(); {
+	case errors.Is(err, bufio.ErrTooLong):
+		return &TruncatedError{r, LimitLineLength, line}
+	case errors.Is(err, errBytesLimit):
+		return &TruncatedError{r, LimitBytes, line}
+	case err != nil:
+		return &ReadError{r, err}
+	}
+
+	return nil
+}
+
+// ErrJobsExhausted is returned by Coordinator.Next if all jobs have been
+// finished.
+var ErrJobsExhausted = errors.New("Jobs exhausted")
+
+// Coordinator coordinates jobs for the crawler. Jobs can be queued, retrieved
+// and marked as finished.  Coordinator tries to make sure every job is
+// retrieved exactly once. A Coordinator is safe for concurrent use.
+type Coordinator struct {
+	mtx      sync.Mutex
+	changed  chan struct{} // closed and replaced whenever jobs change
+	queued   map[string]*Resource
+	delayed  map[string]*delayedJob
+	active   map[string]bool
+	finished VisitedSet
+	done     int // number of finished jobs, finished.Len() may be approximate
+	attempts map[string]int
+	hosts    map[Host]*Host // shared by the queued jobs
+	now      func() time.Time
+}
+
+// delayedJob is a job that has been requeued by RetryJob. It is not retrieved
+// before its due time.
+type delayedJob struct {
+	r   *Resource
+	due time.Time
+}
+
+// NewCoordinator creates and initializes a new Coordinator keeping the
+// finished jobs in a MapSet.
+func NewCoordinator() *Coordinator {
+	return NewCoordinatorWithVisited(NewMapSet())
+}
+
+// NewCoordinatorWithVisited creates and initializes a new Coordinator keeping
+// the finished jobs in VisitedSet finished. Jobs contained in finished are not
+// queued.
+func NewCoordinatorWithVisited(finished VisitedSet) *Coordinator {
+	return &Coordinator{
+		changed:  make(chan struct{}),
+		queued:   make(map[string]*Resource),
+		delayed:  make(map[string]*delayedJob),
+		active:   make(map[string]bool),
+		finished: finished,
+		attempts: make(map[string]int),
+		hosts:    make(map[Host]*Host),
+		now:      time.Now,
+	}
+}
+
+// notify wakes up all goroutines waiting in Next. The caller has to hold the
+// lock.
+func (c *Coordinator) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// String returns an informative string representation. It contains the number
+// of queued jobs and the number of active jobs (retrieved and not marked as
+// finished).
+func (c *Coordinator) String() string {
+	queued, active, finished := c.Counts()
+	return fmt.Sprintf("Queued:%v Active:%v Finished:%v\n", queued, active, finished)
+}
+
+// Counts returns the number of queued, active and finished jobs. Jobs waiting
+// to be retried are counted as queued.
+func (c *Coordinator) Counts() (queued, active, finished int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return len(c.queued) + len(c.delayed), len(c.active), c.done
+}
+
+// QueueJob queues a job to crawl *Resource r. The job is discarded if
+// Coordinator already knows the job. This makes sure that no Resource is
+// crawled multiple times. A *DuplicateJobError is returned if the job has not
+// been queued.
+//
+// The Host of a queued Resource is replaced by a *Host shared by all jobs of
+// the server, so the memory used by Hosts does not grow with the number of
+// selectors crawled. The shared *Hosts live as long as the Coordinator and
+// must not be modified.
+func (c *Coordinator) QueueJob(r *Resource) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.queued[r.String()]; ok {
+		return &DuplicateJobError{r, JobQueued}
+	}
+	if _, ok := c.delayed[r.String()]; ok {
+		return &DuplicateJobError{r, JobQueued}
+	}
+	if c.active[r.String()] {
+		return &DuplicateJobError{r, JobCrawling}
+	}
+	if c.finished.Contains(r.String()) {
+		return &DuplicateJobError{r, JobCrawled}
+	}
+
+	h, ok := c.hosts[*r.Host]
+	if !ok {
+		h = r.Host
+		c.hosts[*h] = h
+	}
+	r.Host = h
+	c.queued[r.String()] = r
+	c.notify()
+	return nil
+}
+
+// QueuedJob retrieves a queued *Resource to crawl and marks the job as active.
+// If no queued job is available, nil is returned.
+func (c *Coordinator) QueuedJob() *Resource {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.takeJob()
+}
+
+// takeJob implements QueuedJob. Jobs waiting to be retried are only retrieved
+// when they are due. The caller has to hold the lock.
+func (c *Coordinator) takeJob() *Resource {
+	for k, r := range c.queued {
+		delete(c.queued, k)
+		c.active[k] = true
+		c.attempts[k]++
+		return r
+	}
+
+	now := c.now()
+	for k, d := range c.delayed {
+		if d.due.After(now) {
+			continue
+		}
+		delete(c.delayed, k)
+		c.active[k] = true
+		c.attempts[k]++
+		return d.r
+	}
+	return nil
+}
+
+// nextDue returns the time until the next job waiting to be retried is due and
+// true, or false if there are no such jobs. The caller has to hold the lock.
+func (c *Coordinator) nextDue() (time.Duration, bool) {
+	var due time.Time
+	for _, d := range c.delayed {
+		if due.IsZero() || d.due.Before(due) {
+			due = d.due
+		}
+	}
+	if due.IsZero() {
+		return 0, false
+	}
+	return due.Sub(c.now()), true
+}
+
+// Next retrieves a queued *Resource to crawl and marks the job as active. If
+// no queued job is available, Next blocks until a job is queued or all jobs
+// have been finished. ErrJobsExhausted is returned in the latter case, the
+// error of ctx if it is done before.
+func (c *Coordinator) Next(ctx context.Context) (*Resource, error) {
+	for {
+		c.mtx.Lock()
+		if r := c.takeJob(); r != nil {
+			c.mtx.Unlock()
+			return r, nil
+		}
+		if c.exhausted() {
+			c.mtx.Unlock()
+			return nil, ErrJobsExhausted
+		}
+		changed := c.changed
+		wait, delayed := c.nextDue()
+		c.mtx.Unlock()
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if delayed {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+
+		var err error
+		select {
+		case <-changed:
+		case <-due:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// RetryJob requeues the active job to crawl *Resource r. The job is not
+// retrieved before delay has passed.
+func (c *Coordinator) RetryJob(r *Resource, delay time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.active, r.String())
+	c.delayed[r.String()] = &delayedJob{r, c.now().Add(delay)}
+	c.notify()
+}
+
+// ParkJob requeues the active job to crawl *Resource r like RetryJob, but the
+// attempt is not counted. It is used for jobs that have not been attempted at
+// all, e.g. because the circuit of their host was open.
+func (c *Coordinator) ParkJob(r *Resource, delay time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	delete(c.active, r.String())
+	c.attempts[r.String()]--
+	c.delayed[r.String()] = &delayedJob{r, c.now().Add(delay)}
+	c.notify()
+}
+
+// Attempts returns the number of times the job to crawl *Resource r has been
+// retrieved. Attempts of finished jobs are forgotten, FinishJob returns the
+// final count.
+func (c *Coordinator) Attempts(r *Resource) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.attempts[r.String()]
+}
+
+// FinishJob marks *Resource r as crawled and returns the number of times the
+// job has been attempted. The job has to be marked active by QueuedJob or
+// Next.
+func (c *Coordinator) FinishJob(r *Resource) int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	k := r.String()
+	attempts := c.attempts[k]
+	delete(c.active, k)
+	delete(c.attempts, k)
+	c.finished.Add(k)
+	c.done++
+	c.notify()
+	return attempts
+}
+
+// JobsExhausted returns true, if all jobs have been finished.
+func (c *Coordinator) JobsExhausted() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.exhausted()
+}
+
+// exhausted implements JobsExhausted. The caller has to hold the lock.
+func (c *Coordinator) exhausted() bool {
+	// We expect at least one finished job (the job to bootstrap the
+	// crawling).
+	return len(c.queued) == 0 && len(c.delayed) == 0 && len(c.active) == 0 &&
+		c.done != 0
+}
+
+// Grapher generates a dotfile describing the relations between gopher servers.
+//
+// The graphed relations grow with the size of the crawl and may be kept in any
+// VisitedSet. The alive servers grow with the number of servers only and have
+// to be kept exactly: a false positive of a BloomSet would make a server look
+// dead. The grawler command therefore always keeps them in a MapSet,
+// regardless of its -visited flag.
+type Grapher struct {
+	// DropSelfLoops suppresses edges from a server to itself.
+	DropSelfLoops bool
+
+	writeCloser io.WriteCloser
+	alive       VisitedSet
+	graphed     VisitedSet
+}
+
+// NewGrapher initializes a new Grapher and returns it. The grapher will write
+// the dotfile using the io.WriteCloser writeCloser.
+func NewGrapher(writeCloser io.WriteCloser) (*Grapher, error) {
+	return NewGrapherWithVisited(writeCloser, NewMapSet(), NewMapSet())
+}
+
+// NewGrapherWithVisited works like NewGrapher, but keeps the servers known to
+// be alive in VisitedSet alive and the graphed server relations in VisitedSet
+// graphed. alive has to be exact, see Grapher.
+func NewGrapherWithVisited(writeCloser io.WriteCloser, alive, graphed VisitedSet) (*Grapher, error) {
+	_, err := io.WriteString(writeCloser, "strict digraph {\n")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Grapher{
+		writeCloser: writeCloser,
+		alive:       alive,
+		graphed:     graphed,
+	}, nil
+}
+
+// GraphFinding generates an edge, describing a server relation defined by
+// *CrawlFinding f.  Every server relation is graphed only once.
+func (g *Grapher) GraphFinding(f *CrawlFinding) error {
+	if f.Parent != nil {
+		if p := f.Parent.String(); !g.alive.Contains(p) {
+			_, err := io.WriteString(g.writeCloser, fmt.Sprintf("\t\"%s\"[alive=true]\n", p))
+			if err != nil {
+				return err
+			}
+			g.alive.Add(p)
+		}
+
+		if g.DropSelfLoops && f.Parent.String() == f.Resource.Host.String() {
+			return nil
+		}
+
+		s := fmt.Sprintf("%v", f)
+		if !g.graphed.Contains(s) {
+			_, err := io.WriteString(g.writeCloser, fmt.Sprintf("\t%s\n", s))
+			if err != nil {
+				return err
+			}
+			g.graphed.Add(s)
+		}
+	}
+	return nil
+}
+
+// AddNodeAttributes adds attributes a to the node of the server identified by
+// its Host string host.
+func (g *Grapher) AddNodeAttributes(host string, a Attributes) error {
+	_, err := io.WriteString(g.writeCloser, fmt.Sprintf("\t\"%s\"%s\n", host, a))
+	return err
+}
+
+// Close closes a Grapher, the data is now ready to be processed using the
+// graphviz visualization toolkit.
+func (g *Grapher) Close() error {
+	defer g.writeCloser.Close()
+
+	_, err := io.WriteString(g.writeCloser, "}\n")
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,10 +2,15 @@ package grawler
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var itemTypeStringTests = []struct {
@@ -26,6 +31,25 @@ func TestItemTypeString(t *testing.T) {
 	}
 }
 
+var itemTypeNameTests = []struct {
+	itemType ItemType
+	expected string
+}{
+	{DirectoryType, "directory"},
+	{ItemType('0'), "text"},
+	{ItemType('i'), "info"},
+	{ItemType('X'), "unknown"},
+}
+
+func TestItemTypeName(t *testing.T) {
+	for _, tt := range itemTypeNameTests {
+		s := tt.itemType.Name()
+		if s != tt.expected {
+			t.Errorf("%q != %q", s, tt.expected)
+		}
+	}
+}
+
 var hostStringTests = []struct {
 	host     *Host
 	expected string
@@ -94,6 +118,30 @@ func TestNewResourceFromGopherLine(t *testing.T) {
 	}
 }
 
+var newItemFromGopherLineTests = []struct {
+	line     string
+	expected string
+}{
+	{"1Root\t\tlocalhost\t70", "Root"},
+	{"1A directory\t/Dir/Selector\tlocalhost\t70\t+", "A directory"},
+	{"2\tFileselector\tgopher.example.com\t72", ""},
+	{"0Text file\tfile.txt\tlocalhost\t70", "Text file"},
+}
+
+func TestNewItemFromGopherLine(t *testing.T) {
+	for _, tt := range newItemFromGopherLineTests {
+		i, err := NewItemFromGopherLine(tt.line)
+		if err != nil {
+			t.Errorf("Parsing %q failed unexpected", tt.line)
+			continue
+		}
+
+		if i.Display != tt.expected {
+			t.Errorf("%q != %q", i.Display, tt.expected)
+		}
+	}
+}
+
 var resourceStringTests = []struct {
 	resource *Resource
 	expected string
@@ -231,8 +279,9 @@ func TestItemAction(t *testing.T) {
 	}()
 
 	iaSet := make(map[string]bool)
-	ia := ItemActionFunc(func(r Resource) {
-		iaSet[r.String()] = true
+	ia := ItemActionFunc(func(i Item) error {
+		iaSet[i.String()] = true
+		return nil
 	})
 
 	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
@@ -261,6 +310,195 @@ func TestItemAction(t *testing.T) {
 	}
 }
 
+func TestItemActionContext(t *testing.T) {
+	findings := make(chan *CrawlFinding)
+	go func() {
+		for range findings {
+		}
+	}()
+	defer close(findings)
+
+	var items []Item
+	ia := ItemActionFunc(func(i Item) error {
+		items = append(items, i)
+		return nil
+	})
+
+	o := func(r *Resource) (io.ReadCloser, error) {
+		s := "iWelcome\t\terror.host\t1\r\n"
+		s += "0About\t/about.txt\tlocalhost\t70\r\n"
+		s += "1Gopher+ directory\t/plus\tlocalhost\t70\t+\r\n"
+		s += "."
+		return newStringReadCloser(s), nil
+	}
+
+	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
+	err := ResourceCrawler(o, r, findings, ia)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []struct {
+		display string
+		line    int
+		plus    bool
+	}{
+		{"About", 2, false},
+		{"Gopher+ directory", 3, true},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Unexpected items: %v", items)
+	}
+	for n, e := range expected {
+		i := items[n]
+		if i.Display != e.display || i.Line != e.line || i.Plus != e.plus {
+			t.Errorf("Unexpected item context: %#v", i)
+		}
+		if i.Parent != r {
+			t.Errorf("Unexpected parent: %v != %v", i.Parent, r)
+		}
+	}
+}
+
+func TestItemActionSkipMenu(t *testing.T) {
+	findings := make(chan *CrawlFinding)
+	findingSet := make(map[string]bool)
+	wait := make(chan bool)
+	go func() {
+		for f := range findings {
+			findingSet[f.Resource.String()] = true
+		}
+		wait <- true
+	}()
+
+	calls := 0
+	ia := ItemActionFunc(func(i Item) error {
+		calls++
+		if i.Type == '2' {
+			return SkipMenu
+		}
+		return nil
+	})
+
+	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
+	err := ResourceCrawler(mockResourceOpener, r, findings, ia)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	close(findings)
+	<-wait
+
+	if calls != 2 {
+		t.Errorf("Unexpected number of item action calls: %d != 2", calls)
+	}
+	if len(findingSet) != 1 || !findingSet["gopher://localhost:70/1"] {
+		t.Errorf("Unexpected findings: %v", findingSet)
+	}
+}
+
+func TestItemActionError(t *testing.T) {
+	findings := make(chan *CrawlFinding)
+	go func() {
+		for range findings {
+		}
+	}()
+	defer close(findings)
+
+	e := fmt.Errorf("Item action failed")
+	ia := ItemActionFunc(func(i Item) error {
+		return e
+	})
+
+	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
+	err := ResourceCrawler(mockResourceOpener, r, findings, ia)
+	if err != e {
+		t.Fatalf("Unexpected error: %v != %v", err, e)
+	}
+}
+
+func mockMenuSize() int64 {
+	rc, _ := mockResourceOpener(nil)
+	n, _ := io.Copy(io.Discard, rc)
+	return n
+}
+
+var crawlWithLimitsTests = []struct {
+	limits   Limits
+	limit    string // expected TruncatedError.Limit, empty for no error
+	lines    int
+	findings int
+}{
+	{Limits{}, "", 0, 2},
+	{Limits{MaxItems: 3}, LimitItems, 3, 1},
+	{Limits{MaxItems: 7}, "", 0, 2},
+	{Limits{MaxLineLength: 20}, LimitLineLength, 0, 0},
+	{Limits{MaxLineLength: 44}, "", 0, 2},
+	{Limits{MaxBytes: 50}, LimitBytes, 2, 0},
+	{Limits{MaxBytes: mockMenuSize()}, "", 0, 2},
+}
+
+func TestCrawlWithLimits(t *testing.T) {
+	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
+	for _, tt := range crawlWithLimitsTests {
+		findings := make(chan *CrawlFinding)
+		n := make(chan int)
+		go func() {
+			c := 0
+			for range findings {
+				c++
+			}
+			n <- c
+		}()
+
+		err := CrawlWithLimits(mockResourceOpener, r, tt.limits, findings)
+		close(findings)
+		if c := <-n; c != tt.findings {
+			t.Errorf("%+v: %d != %d findings", tt.limits, c, tt.findings)
+		}
+
+		if tt.limit == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error: %v", tt.limits, err)
+			}
+			continue
+		}
+
+		var te *TruncatedError
+		if !errors.As(err, &te) {
+			t.Errorf("%+v: unexpected error: %v", tt.limits, err)
+			continue
+		}
+		if te.Limit != tt.limit || te.Lines != tt.lines || te.Resource != r {
+			t.Errorf("%+v: unexpected error: %+v", tt.limits, te)
+		}
+		if ErrorClass(err) != ErrorClassTruncated {
+			t.Errorf("%q != %q", ErrorClass(err), ErrorClassTruncated)
+		}
+	}
+}
+
+func TestResourceCrawlerLongLine(t *testing.T) {
+	o := func(r *Resource) (io.ReadCloser, error) {
+		s := "1Directory\t/\tlocalhost\t70\r\n"
+		s += "i" + strings.Repeat("x", DefaultMaxLineLength) + "\t\terror.host\t1\r\n"
+		s += ".\r\n"
+		return newStringReadCloser(s), nil
+	}
+
+	findings := make(chan *CrawlFinding, 1)
+	r := &Resource{&Host{"localhost", "70"}, DirectoryType, "/"}
+	err := ResourceCrawler(o, r, findings)
+
+	var te *TruncatedError
+	if !errors.As(err, &te) || te.Limit != LimitLineLength || te.Lines != 1 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if len(findings) != 1 {
+		t.Errorf("Finding before long line lost")
+	}
+}
+
 var coordinatorTests = []*Resource{
 	&Resource{&Host{"example.com", "70"}, '1', "/test"},
 	&Resource{&Host{"localhost", "7070"}, '0', "/dummy.txt"},
@@ -332,7 +570,7 @@ func TestCoordinatorQueueJob3(t *testing.T) {
 	}
 
 	for _, ct := range coordinatorTests {
-		c.finished[ct.String()] = true
+		c.finished.Add(ct.String())
 	}
 
 	for _, ct := range coordinatorTests {
@@ -422,17 +660,86 @@ func TestCoordinatorFinishJob(t *testing.T) {
 		t.Fatalf("Number of active jobs unexpected: %d != 0", len(c.active))
 	}
 
-	if len(c.finished) > len(coordinatorTests) {
+	if c.finished.Len() > len(coordinatorTests) {
 		t.Fatalf("Number of finished jobs unexpected: %d != %d", len(c.active), len(coordinatorTests))
 	}
 
 	for _, ct := range coordinatorTests {
-		if _, ok := c.finished[ct.String()]; !ok {
+		if !c.finished.Contains(ct.String()) {
 			t.Errorf("Job %v not found in finished jobs: %#v", ct, c.finished)
 		}
 	}
 }
 
+func TestCoordinatorCounts(t *testing.T) {
+	c := NewCoordinator()
+
+	for _, ct := range coordinatorTests {
+		c.QueueJob(ct)
+	}
+	c.FinishJob(c.QueuedJob())
+
+	queued, active, finished := c.Counts()
+	if queued != len(coordinatorTests)-1 || active != 0 || finished != 1 {
+		t.Fatalf("Unexpected counts: %d, %d, %d", queued, active, finished)
+	}
+
+	c.QueuedJob()
+	queued, active, finished = c.Counts()
+	if queued != len(coordinatorTests)-2 || active != 1 || finished != 1 {
+		t.Fatalf("Unexpected counts: %d, %d, %d", queued, active, finished)
+	}
+}
+
+func TestCoordinatorSharedHosts(t *testing.T) {
+	c := NewCoordinator()
+
+	i1, _ := NewItemFromGopherLine("1Menu\t/\tlocalhost\t70")
+	i2, _ := NewItemFromGopherLine("1Other\t/other\tlocalhost\t70")
+	i3, _ := NewItemFromGopherLine("1Menu\t/\tlocalhost\t7070")
+	if i1.Host == i2.Host {
+		t.Fatalf("%p == %p", i1.Host, i2.Host)
+	}
+	for _, i := range []*Item{i1, i2, i3} {
+		if err := c.QueueJob(&i.Resource); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if i1.Host != i2.Host {
+		t.Errorf("%p != %p", i1.Host, i2.Host)
+	}
+	if i1.Host == i3.Host {
+		t.Errorf("%p == %p", i1.Host, i3.Host)
+	}
+	if *i2.Host != *testHost {
+		t.Errorf("%v != %v", i2.Host, testHost)
+	}
+}
+
+func TestCoordinatorCountsBloom(t *testing.T) {
+	// A BloomSet of two bits, finished jobs set the bits of other jobs.
+	c := NewCoordinatorWithVisited(NewBloomSet(1, 0.5))
+
+	n := 0
+	for i := 0; i < 10; i++ {
+		r := &Resource{testHost, DirectoryType, fmt.Sprintf("/%d", i)}
+		if c.QueueJob(r) == nil {
+			n++
+		}
+	}
+	for j := c.QueuedJob(); j != nil; j = c.QueuedJob() {
+		c.FinishJob(j)
+	}
+
+	if _, _, finished := c.Counts(); finished != n {
+		t.Errorf("%d != %d", finished, n)
+	}
+	if !c.JobsExhausted() {
+		t.Errorf("Jobs unexpectedly not exhausted.")
+	}
+}
+
 func TestCoordinatorJobsExhausted(t *testing.T) {
 	c := NewCoordinator()
 	if c == nil {
@@ -477,6 +784,107 @@ func TestCoordinatorJobsExhausted(t *testing.T) {
 	}
 }
 
+func TestCoordinatorNext(t *testing.T) {
+	c := NewCoordinator()
+
+	jobs := make(chan *Resource)
+	go func() {
+		for {
+			r, err := c.Next(context.Background())
+			if err == ErrJobsExhausted {
+				close(jobs)
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				close(jobs)
+				return
+			}
+			jobs <- r
+		}
+	}()
+
+	// Next blocks until the first job is queued.
+	select {
+	case r := <-jobs:
+		t.Fatalf("Unexpected job: %v", r)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	for _, ct := range coordinatorTests {
+		c.QueueJob(ct)
+	}
+	for range coordinatorTests {
+		r := <-jobs
+		if r == nil {
+			t.Fatal("Jobs unexpectedly exhausted.")
+		}
+		c.FinishJob(r)
+	}
+
+	if r, ok := <-jobs; ok {
+		t.Fatalf("Unexpected job: %v", r)
+	}
+}
+
+func TestCoordinatorNextCancel(t *testing.T) {
+	c := NewCoordinator()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	r, err := c.Next(ctx)
+	if r != nil || err != context.DeadlineExceeded {
+		t.Errorf("Unexpected result: %v, %v", r, err)
+	}
+}
+
+func TestCoordinatorConcurrent(t *testing.T) {
+	c := NewCoordinator()
+
+	const workers, jobs = 8, 1000
+	var mtx sync.Mutex
+	seen := make(map[string]int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				r, err := c.Next(context.Background())
+				if err != nil {
+					return
+				}
+
+				// Every job queues its successor, so the
+				// Coordinator runs dry repeatedly.
+				n, _ := strconv.Atoi(r.Selector)
+				if n < jobs {
+					c.QueueJob(&Resource{r.Host, r.Type, strconv.Itoa(n + 1)})
+				}
+
+				mtx.Lock()
+				seen[r.Selector]++
+				mtx.Unlock()
+				c.FinishJob(r)
+			}
+		}()
+	}
+
+	c.QueueJob(&Resource{&Host{"localhost", "70"}, DirectoryType, "1"})
+	wg.Wait()
+
+	if len(seen) != jobs {
+		t.Errorf("%d != %d", len(seen), jobs)
+	}
+	for s, n := range seen {
+		if n != 1 {
+			t.Errorf("Job %q retrieved %d times", s, n)
+		}
+	}
+}
+
 type mockDotfile struct {
 	bytes.Buffer
 }
@@ -523,3 +931,22 @@ func TestGrapherGraphFinding(t *testing.T) {
 		t.Fatalf("Unexpected dotfile content: %q != %q", nonEmptyDotfile, f.String())
 	}
 }
+
+func TestGrapherDropSelfLoops(t *testing.T) {
+	f := new(mockDotfile)
+	g, err := NewGrapher(f)
+	if err != nil {
+		t.Fatal("NewGrapher failed.")
+	}
+	g.DropSelfLoops = true
+	for _, tt := range crawlFindingStringTests {
+		g.GraphFinding(tt.finding)
+	}
+	g.Close()
+
+	expected := strings.Replace(nonEmptyDotfile,
+		"\t\"gopher.example.com:72\" -> \"gopher.example.com:72\"\n", "", 1)
+	if expected != f.String() {
+		t.Fatalf("Unexpected dotfile content: %q != %q", expected, f.String())
+	}
+}
@@ -0,0 +1,208 @@
+package grawler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/grawlertest"
+)
+
+var resilienceSpec = grawlertest.Spec{
+	"alpha": {
+		Menus: map[string][]grawlertest.Item{
+			"": {
+				{Type: grawler.InformationalMessageType, Display: "Welcome"},
+				{Type: grawler.DirectoryType, Display: "Beta", Host: "beta"},
+				{Type: grawler.DirectoryType, Display: "Gamma", Host: "gamma"},
+			},
+		},
+	},
+	"beta": {
+		Menus: map[string][]grawlertest.Item{
+			"": {
+				// A reset in the middle of the menu cuts this line.
+				{Type: grawler.InformationalMessageType, Display: strings.Repeat("Beta ", 40)},
+				{Type: grawler.DirectoryType, Display: "Sub", Selector: "/sub"},
+				{Type: grawler.DirectoryType, Display: "Gamma", Host: "gamma"},
+			},
+			"/sub": {{Type: '0', Display: "Text", Selector: "/text"}},
+		},
+	},
+	"gamma": {
+		Menus: map[string][]grawlertest.Item{
+			"": {{Type: grawler.DirectoryType, Display: "Delta", Host: "delta"}},
+		},
+	},
+	"delta": {
+		Menus: map[string][]grawlertest.Item{
+			"": {{Type: grawler.DirectoryType, Display: "Alpha", Host: "alpha"}},
+		},
+	},
+}
+
+// dotfile is an in-memory dotfile for a Grapher.
+type dotfile struct {
+	bytes.Buffer
+}
+
+func (d *dotfile) Close() error {
+	return nil
+}
+
+// crawlConcurrently crawls all menus reachable from root using the given
+// number of workers, retrying failed jobs according to *grawler.RetryPolicy
+// p. It returns the graph and the stats of the crawl.
+func crawlConcurrently(t *testing.T, o grawler.ResourceOpener, root *grawler.Resource, workers int, p *grawler.RetryPolicy) (*grawler.Graph, *grawlertest.CrawlStats) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	f := new(dotfile)
+	cfg := grawlertest.Config{Opener: o, Workers: workers, Retry: p}
+	stats, err := grawlertest.Run(ctx, cfg, root, f)
+	if err != nil {
+		t.Fatalf("Crawl did not finish: %v", err)
+	}
+
+	g, err := grawler.ReadGraph(&f.Buffer)
+	if err != nil {
+		t.Fatalf("Invalid dotfile: %v", err)
+	}
+	return g, stats
+}
+
+// resilienceRetries retries failed jobs once, without waiting long.
+var resilienceRetries = &grawler.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}
+
+var resilienceTests = []struct {
+	fault    grawlertest.Fault
+	alive    bool   // beta is alive
+	class    string // error class of beta, empty if no error is expected
+	anyError bool   // beta fails, the error class is not checked
+	attempts int    // attempts to crawl beta
+}{
+	{grawlertest.FaultLatency, true, "", false, 2},
+	{grawlertest.FaultRefuse, false, grawler.ErrorClassRefused, false, 1},
+	{grawlertest.FaultReset, true, "", false, 2},
+	{grawlertest.FaultSlowDrip, true, "", false, 1},
+	{grawlertest.FaultNoTerminator, true, "", false, 1},
+	{grawlertest.FaultLFOnly, true, "", false, 1},
+	{grawlertest.FaultCROnly, true, "", false, 1},
+	{grawlertest.FaultGarbage, false, "", true, 1},
+}
+
+func TestCrawlResilience(t *testing.T) {
+	space, err := grawlertest.Start(resilienceSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	// Every fault is injected into the first attempt to crawl beta only,
+	// transient ones are gone when it is retried. The latency times out.
+	beta := space.Resource("beta", "")
+	for _, tt := range resilienceTests {
+		fi := grawlertest.NewFaultInjector(1)
+		fi.Latency = 50 * time.Millisecond
+		fi.Timeout = 10 * time.Millisecond
+		fi.MaxInjections = 1
+		fi.SetHost(beta.Host, grawlertest.Probabilities{tt.fault: 1})
+
+		g, stats := crawlConcurrently(t, fi.Opener(grawler.NetResourceOpener), space.Resource("alpha", ""), 3, resilienceRetries)
+
+		if n := fi.Injected(tt.fault); n != 1 {
+			t.Errorf("%v: %d != %d", tt.fault, n, 1)
+		}
+		for _, n := range []string{"alpha", "gamma", "delta"} {
+			if g.Nodes[space.Addr(n)]["alive"] != "true" {
+				t.Errorf("%v: %s is not alive", tt.fault, n)
+			}
+		}
+		if alive := g.Nodes[beta.Host.String()]["alive"] == "true"; alive != tt.alive {
+			t.Errorf("%v: %v != %v", tt.fault, alive, tt.alive)
+		}
+		if a := stats.Attempts[beta.String()]; a != tt.attempts {
+			t.Errorf("%v: %d != %d", tt.fault, a, tt.attempts)
+		}
+		if tt.alive && stats.Attempts[space.Resource("beta", "/sub").String()] != 1 {
+			t.Errorf("%v: %s not crawled", tt.fault, space.Resource("beta", "/sub"))
+		}
+
+		errs := stats.Errors
+		err := errs[beta.String()]
+		switch {
+		case tt.anyError && err == nil:
+			t.Errorf("%v: No error", tt.fault)
+		case tt.anyError:
+		case grawler.ErrorClass(err) != tt.class:
+			t.Errorf("%v: %q != %q (%v)", tt.fault, grawler.ErrorClass(err), tt.class, err)
+		}
+		delete(errs, beta.String())
+		for r, err := range errs {
+			t.Errorf("%v: %s: Unexpected error: %v", tt.fault, r, err)
+		}
+	}
+}
+
+func TestCrawlResilienceRefused(t *testing.T) {
+	space, err := grawlertest.Start(resilienceSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	beta := space.Resource("beta", "")
+	fi := grawlertest.NewFaultInjector(1)
+	fi.SetHost(beta.Host, grawlertest.Probabilities{grawlertest.FaultRefuse: 1})
+
+	_, stats := crawlConcurrently(t, fi.Opener(grawler.NetResourceOpener), space.Resource("alpha", ""), 3, resilienceRetries)
+	if err := stats.Errors[beta.String()]; !errors.Is(err, grawler.ErrDial) {
+		t.Errorf("%v is not %v", err, grawler.ErrDial)
+	}
+	if r := space.Requests("beta"); len(r) != 0 {
+		t.Errorf("%q != %q", r, []string{})
+	}
+}
+
+func TestCrawlResilienceMixed(t *testing.T) {
+	space, err := grawlertest.Start(resilienceSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	p := make(grawlertest.Probabilities)
+	for _, f := range []grawlertest.Fault{
+		grawlertest.FaultLatency,
+		grawlertest.FaultRefuse,
+		grawlertest.FaultReset,
+		grawlertest.FaultSlowDrip,
+		grawlertest.FaultNoTerminator,
+		grawlertest.FaultLFOnly,
+		grawlertest.FaultCROnly,
+		grawlertest.FaultGarbage,
+	} {
+		p[f] = 0.2
+	}
+
+	// Whatever faults are injected, the crawl has to finish with a valid
+	// dotfile.
+	injected := 0
+	for seed := int64(1); seed <= 5; seed++ {
+		fi := grawlertest.NewFaultInjector(seed)
+		fi.Latency = time.Millisecond
+		fi.SetDefault(p)
+
+		crawlConcurrently(t, fi.Opener(grawler.NetResourceOpener), space.Resource("alpha", ""), 3, resilienceRetries)
+		for f := range p {
+			injected += fi.Injected(f)
+		}
+	}
+	if injected == 0 {
+		t.Errorf("No faults injected")
+	}
+}
@@ -0,0 +1,90 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Transient returns true, if err is likely to be caused by a temporary
+// condition, so retrying might succeed: timeouts, connection resets and
+// temporary DNS failures. Other errors, e.g. refused connections, unknown
+// hosts or broken hosts, are permanent.
+func Transient(err error) bool {
+	switch ErrorClass(err) {
+	case ErrorClassTimeout, ErrorClassReset, ErrorClassDNS:
+		return true
+	}
+	return false
+}
+
+// RetryPolicy decides whether and when failed jobs are retried. Delays grow
+// exponentially from BaseDelay up to MaxDelay, or up to about the maximum
+// time.Duration if MaxDelay is 0.
+// Every delay is reduced by a random fraction of up to Jitter (between 0 and
+// 1) to spread retries.
+type RetryPolicy struct {
+	MaxAttempts int // including the first attempt
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+
+	// Rand returns a pseudo-random number in [0.0,1.0). The global source of
+	// the math/rand package is used, if Rand is nil.
+	Rand func() float64
+}
+
+// Delay returns the delay before the next attempt after attempts failed
+// attempts.
+func (p *RetryPolicy) Delay(attempts int) time.Duration {
+	d := p.BaseDelay
+	// Stop doubling before d overflows.
+	for i := 1; i < attempts && d > 0 && d <= math.MaxInt64/2 && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		r := rand.Float64
+		if p.Rand != nil {
+			r = p.Rand
+		}
+		d -= time.Duration(float64(d) * p.Jitter * r())
+	}
+	return d
+}
+
+// Retry returns the delay before the next attempt and true, if a job that
+// failed with err after attempts attempts should be retried. Only transient
+// errors are retried.
+func (p *RetryPolicy) Retry(err error, attempts int) (time.Duration, bool) {
+	if err == nil || attempts >= p.MaxAttempts || !Transient(err) {
+		return 0, false
+	}
+	return p.Delay(attempts), true
+}
+
+// Park returns the delay before the next attempt and true, if a job failed
+// with err because the circuit of its host is open and the host is going to be
+// probed. Such a job waits for the probe instead of being finished, see
+// Coordinator.ParkJob. Jobs are not parked any more once a probe of the host
+// failed. The delay is at least BaseDelay.
+func (p *RetryPolicy) Park(err error) (time.Duration, bool) {
+	var hbErr *HostBrokenError
+	if !errors.As(err, &hbErr) || hbErr.ProbeFailed {
+		return 0, false
+	}
+	if hbErr.Wait < p.BaseDelay {
+		return p.BaseDelay, true
+	}
+	return hbErr.Wait, true
+}
@@ -0,0 +1,204 @@
+package grawler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var (
+	errTimeout  = &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	errReset    = &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	errRefused  = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	errNXDOMAIN = &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}
+	errDNS      = &net.DNSError{Err: "server misbehaving", Name: "example.com", IsTemporary: true}
+)
+
+var transientTests = []struct {
+	err      error
+	expected bool
+}{
+	{nil, false},
+	{errTimeout, true},
+	{errReset, true},
+	{errDNS, true},
+	{errRefused, false},
+	{errNXDOMAIN, false},
+	{fmt.Errorf("%w: localhost:70", ErrHostBroken), false},
+	{fmt.Errorf("Resource is not a directory"), false},
+}
+
+func TestTransient(t *testing.T) {
+	for _, tt := range transientTests {
+		if Transient(tt.err) != tt.expected {
+			t.Errorf("%v: %v != %v", tt.err, !tt.expected, tt.expected)
+		}
+	}
+}
+
+var retryTests = []struct {
+	err      error
+	attempts int
+	delay    time.Duration
+	retry    bool
+}{
+	{errTimeout, 1, time.Second, true},
+	{errTimeout, 2, 2 * time.Second, true},
+	{errReset, 3, 4 * time.Second, true},
+	{errReset, 4, 5 * time.Second, true},
+	{errReset, 5, 0, false},
+	{errRefused, 1, 0, false},
+	{nil, 1, 0, false},
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	for _, tt := range retryTests {
+		d, ok := p.Retry(tt.err, tt.attempts)
+		if d != tt.delay || ok != tt.retry {
+			t.Errorf("%v after %d attempts: %v, %v != %v, %v", tt.err, tt.attempts, d, ok, tt.delay, tt.retry)
+		}
+	}
+}
+
+func TestRetryPolicyNoMaxDelay(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second}
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if d := p.Delay(i + 1); d != expected {
+			t.Errorf("%d attempts: %v != %v", i+1, d, expected)
+		}
+	}
+
+	for _, attempts := range []int{64, 1000, math.MaxInt} {
+		if d := p.Delay(attempts); d < p.Delay(40) {
+			t.Errorf("%d attempts: %v < %v", attempts, d, p.Delay(40))
+		}
+	}
+}
+
+func TestRetryPolicyJitter(t *testing.T) {
+	p := &RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+		Jitter:      0.5,
+		Rand:        func() float64 { return 0.5 },
+	}
+	if d := p.Delay(2); d != 1500*time.Millisecond {
+		t.Errorf("%v != %v", d, 1500*time.Millisecond)
+	}
+
+	p.Rand = nil
+	for i := 0; i < 100; i++ {
+		if d := p.Delay(1); d <= 500*time.Millisecond || d > time.Second {
+			t.Fatalf("Delay out of range: %v", d)
+		}
+	}
+}
+
+func TestCoordinatorRetryJob(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCoordinator()
+	c.now = func() time.Time { return now }
+
+	r := coordinatorTests[0]
+	c.QueueJob(r)
+	j := c.QueuedJob()
+	c.RetryJob(j, time.Minute)
+
+	if err := c.QueueJob(r); err == nil {
+		t.Errorf("Delayed job queued again")
+	}
+	if queued, active, _ := c.Counts(); queued != 1 || active != 0 {
+		t.Errorf("Unexpected counts: %d, %d", queued, active)
+	}
+	if c.JobsExhausted() {
+		t.Errorf("Jobs unexpectedly exhausted.")
+	}
+	if j := c.QueuedJob(); j != nil {
+		t.Errorf("Delayed job retrieved early: %v", j)
+	}
+
+	now = now.Add(time.Minute)
+	if j := c.QueuedJob(); j != r {
+		t.Errorf("%v != %v", j, r)
+	}
+	if a := c.Attempts(r); a != 2 {
+		t.Errorf("%d != %d", a, 2)
+	}
+	if a := c.FinishJob(r); a != 2 {
+		t.Errorf("%d != %d", a, 2)
+	}
+	if a := c.Attempts(r); a != 0 {
+		t.Errorf("%d != %d", a, 0)
+	}
+	if !c.JobsExhausted() {
+		t.Errorf("Jobs unexpectedly not exhausted.")
+	}
+}
+
+var parkTests = []struct {
+	err   error
+	delay time.Duration
+	park  bool
+}{
+	{&HostBrokenError{testHost, errTimeout, 4 * time.Minute, false}, 4 * time.Minute, true},
+	{&HostBrokenError{testHost, errTimeout, time.Millisecond, false}, time.Second, true},
+	{&HostBrokenError{testHost, errTimeout, 4 * time.Minute, true}, 0, false},
+	{fmt.Errorf("%w: localhost:70", ErrHostBroken), 0, false},
+	{errTimeout, 0, false},
+	{nil, 0, false},
+}
+
+func TestRetryPolicyPark(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second}
+	for _, tt := range parkTests {
+		d, ok := p.Park(tt.err)
+		if d != tt.delay || ok != tt.park {
+			t.Errorf("%v: %v, %v != %v, %v", tt.err, d, ok, tt.delay, tt.park)
+		}
+	}
+}
+
+func TestCoordinatorParkJob(t *testing.T) {
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewCoordinator()
+	c.now = func() time.Time { return now }
+
+	r := coordinatorTests[0]
+	c.QueueJob(r)
+	c.ParkJob(c.QueuedJob(), time.Minute)
+	if j := c.QueuedJob(); j != nil {
+		t.Errorf("Parked job retrieved early: %v", j)
+	}
+
+	now = now.Add(time.Minute)
+	if j := c.QueuedJob(); j != r {
+		t.Errorf("%v != %v", j, r)
+	}
+	if a := c.Attempts(r); a != 1 {
+		t.Errorf("%d != %d", a, 1)
+	}
+}
+
+func TestCoordinatorNextDelayed(t *testing.T) {
+	c := NewCoordinator()
+
+	r := coordinatorTests[0]
+	c.QueueJob(r)
+	c.RetryJob(c.QueuedJob(), 20*time.Millisecond)
+
+	start := time.Now()
+	j, err := c.Next(context.Background())
+	if err != nil || j != r {
+		t.Fatalf("Unexpected result: %v, %v", j, err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond {
+		t.Errorf("Delayed job retrieved early: %v", d)
+	}
+}
@@ -0,0 +1,270 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawler
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// VisitedSet is a set of visited strings, e.g. crawled URLs or graphed edges.
+// The Coordinator and the Grapher keep their sets for the whole crawl, so the
+// backend decides how memory grows on large crawls. VisitedSets are not safe
+// for concurrent use.
+type VisitedSet interface {
+	Add(s string)
+	Contains(s string) bool
+	Len() int // number of strings added, approximate for a BloomSet
+}
+
+// MapSet is a VisitedSet keeping all strings in memory. It is exact, but its
+// memory grows with every string added.
+type MapSet map[string]struct{}
+
+// NewMapSet creates and initializes a new, empty MapSet.
+func NewMapSet() MapSet {
+	return make(MapSet)
+}
+
+func (m MapSet) Add(s string) {
+	m[s] = struct{}{}
+}
+
+func (m MapSet) Contains(s string) bool {
+	_, ok := m[s]
+	return ok
+}
+
+func (m MapSet) Len() int {
+	return len(m)
+}
+
+// hashString returns the 64-bit FNV-1a hash of s.
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	io.WriteString(h, s)
+	return h.Sum64()
+}
+
+// diskSetBufferSize is the number of bytes buffered by a DiskSet before they
+// are written to its file.
+const diskSetBufferSize = 64 << 10
+
+// DiskSet is an exact VisitedSet keeping the strings in a temporary file. Only
+// a hash and a file offset per string are kept in memory.
+//
+// If reading or writing the file fails, the DiskSet keeps working in memory
+// and the error is reported by Err.
+type DiskSet struct {
+	f       *os.File
+	flushed int64  // number of bytes written to f
+	buf     []byte // bytes not yet written to f
+
+	index map[uint64]int64   // offset of the first string with a hash
+	more  map[uint64][]int64 // offsets of further strings with the hash
+	n     int
+	err   error
+	hash  func(string) uint64
+}
+
+// NewDiskSet creates a new, empty DiskSet keeping its temporary file in
+// directory dir, see os.CreateTemp. The file is removed by Close.
+func NewDiskSet(dir string) (*DiskSet, error) {
+	f, err := os.CreateTemp(dir, "grawler-visited-")
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiskSet{
+		f:     f,
+		index: make(map[uint64]int64),
+		more:  make(map[uint64][]int64),
+		hash:  hashString,
+	}, nil
+}
+
+// setErr records the first error.
+func (d *DiskSet) setErr(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+// Err returns the first error reading or writing the file.
+func (d *DiskSet) Err() error {
+	return d.err
+}
+
+// flush writes the buffered bytes to the file. If writing fails, the bytes
+// stay buffered.
+func (d *DiskSet) flush() {
+	if d.err != nil {
+		return
+	}
+	n, err := d.f.WriteAt(d.buf, d.flushed)
+	d.flushed += int64(n)
+	d.buf = d.buf[n:]
+	if err != nil {
+		d.setErr(err)
+		return
+	}
+	d.buf = d.buf[:0]
+}
+
+// read returns the string stored at offset off.
+func (d *DiskSet) read(off int64) (string, bool) {
+	if off >= d.flushed {
+		b := d.buf[off-d.flushed:]
+		l, n := binary.Uvarint(b)
+		return string(b[n : n+int(l)]), true
+	}
+
+	var head [binary.MaxVarintLen64]byte
+	n, err := d.f.ReadAt(head[:], off)
+	if n == 0 {
+		d.setErr(err)
+		return "", false
+	}
+	l, n := binary.Uvarint(head[:n])
+	b := make([]byte, l)
+	if _, err := d.f.ReadAt(b, off+int64(n)); err != nil {
+		d.setErr(err)
+		return "", false
+	}
+	return string(b), true
+}
+
+func (d *DiskSet) Contains(s string) bool {
+	h := d.hash(s)
+	off, ok := d.index[h]
+	if !ok {
+		return false
+	}
+	if v, ok := d.read(off); ok && v == s {
+		return true
+	}
+	for _, off := range d.more[h] {
+		if v, ok := d.read(off); ok && v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *DiskSet) Add(s string) {
+	if d.Contains(s) {
+		return
+	}
+
+	off := d.flushed + int64(len(d.buf))
+	d.buf = binary.AppendUvarint(d.buf, uint64(len(s)))
+	d.buf = append(d.buf, s...)
+	if len(d.buf) >= diskSetBufferSize {
+		d.flush()
+	}
+
+	h := d.hash(s)
+	if _, ok := d.index[h]; ok {
+		d.more[h] = append(d.more[h], off)
+	} else {
+		d.index[h] = off
+	}
+	d.n++
+}
+
+func (d *DiskSet) Len() int {
+	return d.n
+}
+
+// Close closes and removes the file of the DiskSet.
+func (d *DiskSet) Close() error {
+	err := d.f.Close()
+	if rerr := os.Remove(d.f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// BloomSet is a probabilistic VisitedSet using a Bloom filter. Its memory does
+// not grow, but Contains may return true for strings that have not been added.
+// Used by the Coordinator such a false positive skips a job, used for the
+// graphed relations of the Grapher it drops an edge. Do not use it for the
+// alive servers of the Grapher: a false positive would make a server look
+// dead.
+type BloomSet struct {
+	bits []uint64
+	m    uint64 // number of bits
+	k    int    // number of hash functions
+	n    int
+}
+
+// NewBloomSet creates a new, empty BloomSet sized for capacity strings with
+// a false positive rate of fpRate.
+func NewBloomSet(capacity int, fpRate float64) *BloomSet {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.001
+	}
+
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := int(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomSet{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// positions calls f with the bit positions of s, until f returns false.
+func (b *BloomSet) positions(s string, f func(uint64) bool) {
+	// Double hashing, see Kirsch and Mitzenmacher, "Less Hashing, Same
+	// Performance: Building a Better Bloom Filter".
+	h1 := hashString(s)
+	h2 := (h1>>33 | h1<<31) * 0x9e3779b97f4a7c15
+	for i := 0; i < b.k; i++ {
+		if !f((h1 + uint64(i)*h2) % b.m) {
+			return
+		}
+	}
+}
+
+func (b *BloomSet) Add(s string) {
+	added := false
+	b.positions(s, func(p uint64) bool {
+		if b.bits[p/64]&(1<<(p%64)) == 0 {
+			b.bits[p/64] |= 1 << (p % 64)
+			added = true
+		}
+		return true
+	})
+	if added {
+		b.n++
+	}
+}
+
+func (b *BloomSet) Contains(s string) bool {
+	found := true
+	b.positions(s, func(p uint64) bool {
+		found = b.bits[p/64]&(1<<(p%64)) != 0
+		return found
+	})
+	return found
+}
+
+// Len returns the approximate number of strings added. Strings whose bits
+// have all been set already, e.g. false positives, are not counted.
+func (b *BloomSet) Len() int {
+	return b.n
+}
@@ -0,0 +1,128 @@
+package grawler
+
+import (
+	"fmt"
+	"testing"
+)
+
+var visitedStrings = []string{
+	"gopher://localhost:70/1/",
+	"gopher://localhost:70/0/about.txt",
+	"gopher://localhost:7070/1/",
+	"",
+	"\"localhost:70\" -> \"localhost:7070\"",
+}
+
+// newVisitedSets returns a VisitedSet of every kind.
+func newVisitedSets(t *testing.T) map[string]VisitedSet {
+	d, err := NewDiskSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { d.Close() })
+
+	return map[string]VisitedSet{
+		"map":   NewMapSet(),
+		"disk":  d,
+		"bloom": NewBloomSet(1000, 0.001),
+	}
+}
+
+func TestVisitedSet(t *testing.T) {
+	for name, v := range newVisitedSets(t) {
+		for i, s := range visitedStrings {
+			if v.Contains(s) {
+				t.Errorf("%s: %q contained before it has been added", name, s)
+			}
+			v.Add(s)
+			v.Add(s)
+			if !v.Contains(s) {
+				t.Errorf("%s: %q not contained after it has been added", name, s)
+			}
+			if v.Len() != i+1 {
+				t.Errorf("%s: %d != %d", name, v.Len(), i+1)
+			}
+		}
+	}
+}
+
+func TestDiskSetCollisions(t *testing.T) {
+	d, err := NewDiskSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer d.Close()
+	d.hash = func(string) uint64 { return 42 }
+
+	for _, s := range visitedStrings[:3] {
+		d.Add(s)
+	}
+	for i, s := range visitedStrings {
+		if expected := i < 3; d.Contains(s) != expected {
+			t.Errorf("%q: %v != %v", s, d.Contains(s), expected)
+		}
+	}
+	if d.Len() != 3 {
+		t.Errorf("%d != %d", d.Len(), 3)
+	}
+}
+
+func TestDiskSetFlushed(t *testing.T) {
+	d, err := NewDiskSet(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer d.Close()
+
+	n := 20000
+	for i := 0; i < n; i++ {
+		d.Add(fmt.Sprintf("gopher://host%d.example:70/1/", i))
+	}
+	if d.flushed == 0 {
+		t.Fatal("Nothing has been written to the file")
+	}
+	for i := 0; i < n; i++ {
+		if s := fmt.Sprintf("gopher://host%d.example:70/1/", i); !d.Contains(s) {
+			t.Errorf("%q not contained", s)
+		}
+	}
+	if s := fmt.Sprintf("gopher://host%d.example:70/1/", n); d.Contains(s) {
+		t.Errorf("%q contained", s)
+	}
+	if d.Len() != n {
+		t.Errorf("%d != %d", d.Len(), n)
+	}
+	if err := d.Err(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestBloomSetFalsePositiveRate(t *testing.T) {
+	for _, p := range []float64{0.1, 0.01, 0.001} {
+		n := 10000
+		b := NewBloomSet(n, p)
+		for i := 0; i < n; i++ {
+			b.Add(fmt.Sprintf("gopher://host%d.example:70/1/", i))
+		}
+
+		fp := 0
+		for i := n; i < 2*n; i++ {
+			if b.Contains(fmt.Sprintf("gopher://host%d.example:70/1/", i)) {
+				fp++
+			}
+		}
+		if rate := float64(fp) / float64(n); rate > 2*p {
+			t.Errorf("%v: false positive rate %v", p, rate)
+		}
+	}
+}
+
+func TestCoordinatorWithVisited(t *testing.T) {
+	finished := NewMapSet()
+	finished.Add(testMenu.String())
+
+	c := NewCoordinatorWithVisited(finished)
+	if err := c.QueueJob(testMenu); err == nil {
+		t.Errorf("No error while queuing visited job: %v", testMenu)
+	}
+}
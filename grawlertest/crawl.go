@@ -0,0 +1,118 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawlertest
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// CrawlStats summarizes a crawl run by Run.
+type CrawlStats struct {
+	Menus    int // number of crawled menus, including failed ones
+	Findings int
+	Duration time.Duration
+
+	// HeapAlloc is the number of bytes of allocated heap objects at the
+	// end of the crawl, while the Coordinator and Grapher are still in use.
+	HeapAlloc uint64
+
+	// Errors maps the failed menus to the errors of their last attempts.
+	Errors map[string]error
+
+	// Attempts maps the crawled menus to the number of their attempts.
+	Attempts map[string]int
+}
+
+// Config configures a crawl run by Run.
+type Config struct {
+	Opener  grawler.ResourceOpener
+	Workers int
+
+	// Retry decides whether failed jobs are retried. Failed jobs are not
+	// retried, if Retry is nil.
+	Retry *grawler.RetryPolicy
+
+	// NewSet returns the VisitedSets keeping the crawled resources and
+	// graphed relations. MapSets are used, if NewSet is nil.
+	NewSet func() grawler.VisitedSet
+}
+
+// Crawl crawls all menus reachable from root, see Run. Failed jobs are not
+// retried.
+func Crawl(ctx context.Context, o grawler.ResourceOpener, root *grawler.Resource, workers int, w io.WriteCloser) (*CrawlStats, error) {
+	return Run(ctx, Config{Opener: o, Workers: workers}, root, w)
+}
+
+// CrawlWithVisited works like Crawl, but the Coordinator and the Grapher keep
+// the crawled resources and graphed relations in the VisitedSets returned by
+// newSet.
+func CrawlWithVisited(ctx context.Context, o grawler.ResourceOpener, root *grawler.Resource, workers int, w io.WriteCloser, newSet func() grawler.VisitedSet) (*CrawlStats, error) {
+	return Run(ctx, Config{Opener: o, Workers: workers, NewSet: newSet}, root, w)
+}
+
+// Run crawls all menus reachable from root using a grawler.Crawler, the way
+// the grawler command does: jobs are coordinated by a Coordinator and crawled
+// by the number of workers of cfg, findings are graphed by a Grapher writing
+// to w. The alive servers are kept in a MapSet. The crawl is aborted with the
+// error of ctx, if ctx is done before. Run returns after all workers have
+// stopped.
+func Run(ctx context.Context, cfg Config, root *grawler.Resource, w io.WriteCloser) (*CrawlStats, error) {
+	newSet := cfg.NewSet
+	if newSet == nil {
+		newSet = func() grawler.VisitedSet { return grawler.NewMapSet() }
+	}
+
+	stats := &CrawlStats{Errors: make(map[string]error), Attempts: make(map[string]int)}
+	start := time.Now()
+
+	coord := grawler.NewCoordinatorWithVisited(newSet())
+	grapher, err := grawler.NewGrapherWithVisited(w, grawler.NewMapSet(), newSet())
+	if err != nil {
+		return nil, err
+	}
+
+	c := &grawler.Crawler{
+		Opener:  cfg.Opener,
+		Workers: cfg.Workers,
+		Retry:   cfg.Retry,
+		OnFinding: func(f *grawler.CrawlFinding, err error) error {
+			if f.Parent != nil {
+				stats.Findings++
+			}
+			return grapher.GraphFinding(f)
+		},
+		OnJobDone: func(res *grawler.JobResult) {
+			stats.Menus++
+			stats.Attempts[res.Resource.String()] = res.Attempt
+			if res.Err != nil {
+				stats.Errors[res.Resource.String()] = res.Err
+			}
+		},
+	}
+	if err := c.Crawl(ctx, coord, root); err != nil {
+		grapher.Close()
+		return nil, err
+	}
+	stats.Duration = time.Since(start)
+
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	stats.HeapAlloc = m.HeapAlloc
+	runtime.KeepAlive(coord)
+	runtime.KeepAlive(grapher)
+
+	if err := grapher.Close(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
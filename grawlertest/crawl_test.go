@@ -0,0 +1,39 @@
+package grawlertest
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+func TestCrawlCancel(t *testing.T) {
+	s := NewSynthetic(SyntheticConfig{Hosts: 1000, Menus: 10, MaxDegree: 20, Seed: 1})
+	base := runtime.NumGoroutine()
+
+	// The crawl is cancelled while the workers are sending findings.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var once sync.Once
+	o := func(r *grawler.Resource) (io.ReadCloser, error) {
+		once.Do(cancel)
+		return s.Opener()(r)
+	}
+
+	_, err := Crawl(ctx, o, s.Root(), 8, discard{})
+	if err != context.Canceled {
+		t.Errorf("%v != %v", err, context.Canceled)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > base && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > base {
+		t.Errorf("%d goroutines leaked", n-base)
+	}
+}
@@ -0,0 +1,266 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawlertest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Fault is a fault injected by a FaultInjector.
+type Fault int
+
+// Faults injected by a FaultInjector, in the order they are rolled.
+const (
+	FaultLatency      Fault = iota // delay opening the resource
+	FaultRefuse                    // refuse the connection
+	FaultReset                     // reset the connection mid-stream
+	FaultSlowDrip                  // deliver the response byte by byte
+	FaultNoTerminator              // drop the terminating "." line
+	FaultLFOnly                    // end lines with LF only
+	FaultCROnly                    // end lines with CR only
+	FaultGarbage                   // replace the response by binary garbage
+	numFaults
+)
+
+// String returns the name of the Fault.
+func (f Fault) String() string {
+	switch f {
+	case FaultLatency:
+		return "latency"
+	case FaultRefuse:
+		return "refuse"
+	case FaultReset:
+		return "reset"
+	case FaultSlowDrip:
+		return "slow_drip"
+	case FaultNoTerminator:
+		return "no_terminator"
+	case FaultLFOnly:
+		return "lf_only"
+	case FaultCROnly:
+		return "cr_only"
+	case FaultGarbage:
+		return "garbage"
+	}
+	return fmt.Sprintf("Fault(%d)", int(f))
+}
+
+// Probabilities maps Faults to the probability, between 0 and 1, that they
+// are injected when a resource is opened.
+type Probabilities map[Fault]float64
+
+// FaultInjector injects faults into the resources opened by a ResourceOpener.
+// Every Fault is rolled independently with the Probabilities of the host of
+// the resource, so several faults may be injected at once. The pseudo-random
+// source is seeded, so the faults injected into a sequence of resources are
+// reproducible. A FaultInjector is safe for concurrent use.
+type FaultInjector struct {
+	Latency   time.Duration // delay injected by FaultLatency
+	DripDelay time.Duration // delay per byte injected by FaultSlowDrip
+
+	// Timeout is the timeout of the client opening the resources, 0 for
+	// none. Resources delayed by a Latency of at least Timeout fail with
+	// a timeout after Timeout.
+	Timeout time.Duration
+
+	// MaxInjections is the maximum number of times every Fault is
+	// injected, 0 for no limit. Retries of a resource succeed once its
+	// faults are used up.
+	MaxInjections int
+
+	mtx      sync.Mutex
+	rand     *rand.Rand
+	hosts    map[string]Probabilities
+	fallback Probabilities
+	injected map[Fault]int
+}
+
+// NewFaultInjector creates and initializes a new FaultInjector using seed for
+// its pseudo-random source. No faults are injected until probabilities are
+// set.
+func NewFaultInjector(seed int64) *FaultInjector {
+	return &FaultInjector{
+		Latency:   100 * time.Millisecond,
+		DripDelay: time.Millisecond,
+		rand:      rand.New(rand.NewSource(seed)),
+		hosts:     make(map[string]Probabilities),
+		injected:  make(map[Fault]int),
+	}
+}
+
+// SetHost sets the Probabilities of the faults injected into resources of
+// *grawler.Host h.
+func (fi *FaultInjector) SetHost(h *grawler.Host, p Probabilities) {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	fi.hosts[h.String()] = p
+}
+
+// SetDefault sets the Probabilities used for hosts without their own.
+func (fi *FaultInjector) SetDefault(p Probabilities) {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	fi.fallback = p
+}
+
+// Injected returns the number of times Fault f has been injected.
+func (fi *FaultInjector) Injected(f Fault) int {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	return fi.injected[f]
+}
+
+// roll returns the faults to inject into a resource of *grawler.Host h.
+func (fi *FaultInjector) roll(h *grawler.Host) map[Fault]bool {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	p, ok := fi.hosts[h.String()]
+	if !ok {
+		p = fi.fallback
+	}
+
+	faults := make(map[Fault]bool)
+	for f := Fault(0); f < numFaults; f++ {
+		// Always roll, so the sequence of random numbers does not
+		// depend on the probabilities.
+		if fi.rand.Float64() < p[f] &&
+			(fi.MaxInjections <= 0 || fi.injected[f] < fi.MaxInjections) {
+			faults[f] = true
+			fi.injected[f]++
+		}
+	}
+	return faults
+}
+
+// garbage returns n pseudo-random bytes.
+func (fi *FaultInjector) garbage(n int) []byte {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	b := make([]byte, n)
+	fi.rand.Read(b)
+	return b
+}
+
+// Opener returns a ResourceOpener that opens resources using ResourceOpener o
+// and injects faults. Responses are read completely from o before faults are
+// injected into them.
+func (fi *FaultInjector) Opener(o grawler.ResourceOpener) grawler.ResourceOpener {
+	return func(r *grawler.Resource) (io.ReadCloser, error) {
+		faults := fi.roll(r.Host)
+
+		if faults[FaultLatency] {
+			if fi.Timeout > 0 && fi.Latency >= fi.Timeout {
+				time.Sleep(fi.Timeout)
+				return nil, timedOut(r.Host)
+			}
+			time.Sleep(fi.Latency)
+		}
+		if faults[FaultRefuse] {
+			return nil, refused(r.Host)
+		}
+
+		rc, err := o(r)
+		if err != nil {
+			return nil, err
+		}
+		b, readErr := io.ReadAll(rc)
+		rc.Close()
+
+		if faults[FaultNoTerminator] {
+			b = dropTerminator(b)
+		}
+		if faults[FaultLFOnly] {
+			b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+		}
+		if faults[FaultCROnly] {
+			b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\r"))
+			b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r"))
+		}
+		if faults[FaultGarbage] {
+			b = fi.garbage(len(b) + 64)
+		}
+
+		var in io.Reader = bytes.NewReader(b)
+		if faults[FaultReset] {
+			readErr = &net.OpError{Op: "read", Net: "tcp",
+				Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+			in = io.LimitReader(in, int64(len(b)/2))
+		}
+		if readErr != nil {
+			in = io.MultiReader(in, &errReader{readErr})
+		}
+		if faults[FaultSlowDrip] {
+			in = &dripReader{in, fi.DripDelay}
+		}
+		return io.NopCloser(in), nil
+	}
+}
+
+// refused returns the error of a connection to *grawler.Host h that has been
+// refused.
+func refused(h *grawler.Host) error {
+	err := &net.OpError{Op: "dial", Net: "tcp",
+		Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	return &grawler.DialError{Host: h, Err: err}
+}
+
+// timedOut returns the error of a connection to *grawler.Host h that timed
+// out.
+func timedOut(h *grawler.Host) error {
+	err := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+	return &grawler.DialError{Host: h, Err: err}
+}
+
+// dropTerminator removes the terminating "." line and everything after it
+// from b.
+func dropTerminator(b []byte) []byte {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	for i, l := range lines {
+		if string(bytes.TrimRight(l, "\r\n")) == "." {
+			return bytes.Join(lines[:i], nil)
+		}
+	}
+	return b
+}
+
+// errReader is an io.Reader always failing with err.
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// dripReader reads a single byte from r after every delay.
+type dripReader struct {
+	r     io.Reader
+	delay time.Duration
+}
+
+func (r *dripReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	time.Sleep(r.delay)
+	return r.r.Read(p[:1])
+}
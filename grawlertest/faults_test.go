@@ -0,0 +1,112 @@
+package grawlertest
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+const faultsMenu = "iA\t\th\t1\r\niB\t\th\t1\r\n.\r\n"
+
+func faultsOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(faultsMenu)), nil
+}
+
+var faultTests = []struct {
+	fault    Fault
+	expected string
+	err      error
+}{
+	{FaultLatency, faultsMenu, nil},
+	{FaultSlowDrip, faultsMenu, nil},
+	{FaultNoTerminator, "iA\t\th\t1\r\niB\t\th\t1\r\n", nil},
+	{FaultLFOnly, "iA\t\th\t1\niB\t\th\t1\n.\n", nil},
+	{FaultCROnly, "iA\t\th\t1\riB\t\th\t1\r.\r", nil},
+	{FaultReset, faultsMenu[:len(faultsMenu)/2], syscall.ECONNRESET},
+}
+
+func TestFaultInjector(t *testing.T) {
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType}
+	for _, tt := range faultTests {
+		fi := NewFaultInjector(1)
+		fi.Latency = 0
+		fi.SetHost(r.Host, Probabilities{tt.fault: 1})
+
+		rc, err := fi.Opener(faultsOpener)(r)
+		if err != nil {
+			t.Fatalf("%v: Unexpected error: %v", tt.fault, err)
+		}
+		b, err := io.ReadAll(rc)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: %v != %v", tt.fault, err, tt.err)
+		}
+		if string(b) != tt.expected {
+			t.Errorf("%v: %q != %q", tt.fault, b, tt.expected)
+		}
+		if n := fi.Injected(tt.fault); n != 1 {
+			t.Errorf("%v: %d != %d", tt.fault, n, 1)
+		}
+	}
+}
+
+func TestFaultInjectorRefuse(t *testing.T) {
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType}
+	fi := NewFaultInjector(1)
+	fi.SetDefault(Probabilities{FaultRefuse: 1})
+
+	_, err := fi.Opener(faultsOpener)(r)
+	if !errors.Is(err, grawler.ErrDial) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("%v is not a refused connection", err)
+	}
+}
+
+func TestFaultInjectorTimeout(t *testing.T) {
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType}
+	fi := NewFaultInjector(1)
+	fi.Latency = time.Second
+	fi.Timeout = time.Millisecond
+	fi.SetDefault(Probabilities{FaultLatency: 1})
+
+	_, err := fi.Opener(faultsOpener)(r)
+	if !errors.Is(err, grawler.ErrTimeout) {
+		t.Errorf("%v is not %v", err, grawler.ErrTimeout)
+	}
+}
+
+func TestFaultInjectorMaxInjections(t *testing.T) {
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType}
+	fi := NewFaultInjector(1)
+	fi.MaxInjections = 2
+	fi.SetDefault(Probabilities{FaultRefuse: 1})
+
+	for i, refused := range []bool{true, true, false} {
+		_, err := fi.Opener(faultsOpener)(r)
+		if (err != nil) != refused {
+			t.Errorf("%d: %v != %v (%v)", i, err != nil, refused, err)
+		}
+	}
+	if n := fi.Injected(FaultRefuse); n != 2 {
+		t.Errorf("%d != %d", n, 2)
+	}
+}
+
+func TestFaultInjectorSeed(t *testing.T) {
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType}
+
+	var garbage []string
+	for i := 0; i < 2; i++ {
+		fi := NewFaultInjector(42)
+		fi.SetDefault(Probabilities{FaultGarbage: 1})
+		rc, _ := fi.Opener(faultsOpener)(r)
+		b, _ := io.ReadAll(rc)
+		garbage = append(garbage, string(b))
+	}
+	if garbage[0] == faultsMenu || garbage[0] != garbage[1] {
+		t.Errorf("%q != %q", garbage[0], garbage[1])
+	}
+}
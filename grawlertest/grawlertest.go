@@ -0,0 +1,354 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Fake gopher servers on the loopback interface for testing.
+//
+// A Spec declares the hosts of a small gopherspace, their menus, text files,
+// failures and delays. Start serves every host of the Spec from its own
+// loopback listener, so crawls can be tested end-to-end without a network:
+//
+//	space, err := grawlertest.Start(grawlertest.Spec{
+//		"alpha": {Menus: map[string][]grawlertest.Item{
+//			"": {{Type: '1', Display: "Beta", Host: "beta"}},
+//		}},
+//		"beta": {Down: true},
+//	})
+//	defer space.Close()
+//	root := space.Resource("alpha", "")
+package grawlertest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/blabber/grawler/gopher"
+	"github.com/blabber/grawler/grawler"
+)
+
+// Spec declares a gopherspace. It maps host names to their Host declaration.
+// The names are only used within the Spec, every host is served on its own
+// loopback address.
+type Spec map[string]*Host
+
+// Host declares a gopher server.
+type Host struct {
+	// Menus maps selectors to menus, Texts maps selectors to text files.
+	// Requests for unknown selectors are answered by an error menu.
+	Menus map[string][]Item
+	Texts map[string]string
+
+	// Failures maps selectors to the Failure occurring when they are
+	// requested.
+	Failures map[string]Failure
+
+	// Delay is waited before every response.
+	Delay time.Duration
+
+	// Down hosts refuse the connections dialed by the Gopherspace, see
+	// DialContext. Their listeners stay open, so their ports are not
+	// reused by other listeners. Connections accepted anyway, e.g. dialed
+	// by a net.Dialer, are reset.
+	Down bool
+}
+
+// Item is a menu item. Host is the name of the referenced host in the Spec,
+// the serving host is referenced if Host is empty. Names not found in the
+// Spec are used verbatim as hostname, with port 70.
+type Item struct {
+	Type     grawler.ItemType
+	Display  string
+	Selector string
+	Host     string
+}
+
+// Failure is a way a request may fail.
+type Failure int
+
+// Failures of a request.
+const (
+	FailNone     Failure = iota
+	FailClose            // close the connection without response
+	FailReset            // reset the connection without response
+	FailHang             // never respond, the client has to give up
+	FailTruncate         // send the first half of the lines without terminator
+	FailGarbage          // respond with a line that is not a valid menu line
+)
+
+// server serves a single host of a Gopherspace.
+type server struct {
+	name string
+	host *Host
+	l    net.Listener
+	addr string
+
+	mtx      sync.Mutex
+	requests []string
+}
+
+// Gopherspace is a running gopherspace started from a Spec.
+type Gopherspace struct {
+	servers map[string]*server
+	wg      sync.WaitGroup
+	closing chan struct{}
+
+	mtx   sync.Mutex
+	conns map[net.Conn]bool
+}
+
+// Start serves all hosts of Spec spec on loopback listeners. The Gopherspace
+// has to be closed after use.
+func Start(spec Spec) (*Gopherspace, error) {
+	g := &Gopherspace{
+		servers: make(map[string]*server),
+		closing: make(chan struct{}),
+		conns:   make(map[net.Conn]bool),
+	}
+
+	for name, h := range spec {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			g.Close()
+			return nil, fmt.Errorf("Could not listen for %s: %v", name, err)
+		}
+		g.servers[name] = &server{name: name, host: h, l: l, addr: l.Addr().String()}
+	}
+
+	// The servers resolve the names of other hosts, so all of them are
+	// created before serving any.
+	for _, s := range g.servers {
+		g.wg.Add(1)
+		go g.serve(s)
+	}
+
+	return g, nil
+}
+
+// Close stops all servers of the Gopherspace and closes their connections.
+func (g *Gopherspace) Close() {
+	close(g.closing)
+	for _, s := range g.servers {
+		s.l.Close()
+	}
+
+	g.mtx.Lock()
+	for c := range g.conns {
+		c.Close()
+	}
+	g.mtx.Unlock()
+
+	g.wg.Wait()
+}
+
+// DialContext connects to the address on the named network like a
+// net.Dialer, but connections to the hosts that are down are refused. A
+// Gopherspace is a gopher.Dialer.
+func (g *Gopherspace) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	for _, s := range g.servers {
+		if s.host.Down && s.addr == address {
+			return nil, &net.OpError{Op: "dial", Net: network, Addr: s.l.Addr(),
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+		}
+	}
+
+	var d net.Dialer
+	return d.DialContext(ctx, network, address)
+}
+
+// Client returns a new gopher.Client dialing the hosts of the Gopherspace
+// using DialContext.
+func (g *Gopherspace) Client() *gopher.Client {
+	return &gopher.Client{Dialer: g}
+}
+
+// Addr returns the address ("host:port") of the host with the name.
+func (g *Gopherspace) Addr(name string) string {
+	if s, ok := g.servers[name]; ok {
+		return s.addr
+	}
+	return net.JoinHostPort(name, "70")
+}
+
+// Host returns the *grawler.Host of the host with the name.
+func (g *Gopherspace) Host(name string) *grawler.Host {
+	h, _ := grawler.ParseHost(g.Addr(name))
+	return h
+}
+
+// Resource returns the directory selector of the host with the name.
+func (g *Gopherspace) Resource(name, selector string) *grawler.Resource {
+	return &grawler.Resource{Host: g.Host(name), Type: grawler.DirectoryType, Selector: selector}
+}
+
+// Name returns the name of the host serving *grawler.Host h, or an empty
+// string if h is not part of the Gopherspace.
+func (g *Gopherspace) Name(h *grawler.Host) string {
+	for name, s := range g.servers {
+		if s.addr == h.String() {
+			return name
+		}
+	}
+	return ""
+}
+
+// Requests returns the selectors requested from the host with the name, in
+// the order the requests have been received.
+func (g *Gopherspace) Requests(name string) []string {
+	s, ok := g.servers[name]
+	if !ok {
+		return nil
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Names returns the sorted names of all hosts of the Gopherspace.
+func (g *Gopherspace) Names() []string {
+	var names []string
+	for name := range g.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// serve accepts the connections of server s.
+func (g *Gopherspace) serve(s *server) {
+	defer g.wg.Done()
+
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		if s.host.Down {
+			reset(conn)
+			continue
+		}
+
+		g.mtx.Lock()
+		g.conns[conn] = true
+		g.mtx.Unlock()
+
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			defer func() {
+				g.mtx.Lock()
+				delete(g.conns, conn)
+				g.mtx.Unlock()
+				conn.Close()
+			}()
+			g.handle(s, conn)
+		}()
+	}
+}
+
+// handle answers a single request to server s.
+func (g *Gopherspace) handle(s *server, conn net.Conn) {
+	request, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return
+	}
+	selector := strings.TrimRight(request, "\r\n")
+	if i := strings.IndexByte(selector, '\t'); i >= 0 {
+		selector = selector[:i]
+	}
+
+	s.mtx.Lock()
+	s.requests = append(s.requests, selector)
+	s.mtx.Unlock()
+
+	if s.host.Delay > 0 {
+		select {
+		case <-time.After(s.host.Delay):
+		case <-g.closing:
+			return
+		}
+	}
+
+	response := g.response(s, selector)
+	switch s.host.Failures[selector] {
+	case FailClose:
+		return
+	case FailReset:
+		reset(conn)
+		return
+	case FailHang:
+		<-g.closing
+		return
+	case FailTruncate:
+		lines := strings.SplitAfter(strings.TrimSuffix(response, ".\r\n"), "\r\n")
+		response = strings.Join(lines[:len(lines)/2], "")
+	case FailGarbage:
+		response = "This is not a gopher menu\r\n.\r\n"
+	}
+
+	io.WriteString(conn, response)
+}
+
+// reset closes conn, resetting the connection instead of shutting it down
+// gracefully.
+func reset(conn net.Conn) {
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// response returns the response of server s for selector.
+func (g *Gopherspace) response(s *server, selector string) string {
+	if t, ok := s.host.Texts[selector]; ok {
+		var b strings.Builder
+		for _, l := range strings.SplitAfter(t, "\n") {
+			if strings.HasPrefix(l, ".") {
+				b.WriteString(".")
+			}
+			b.WriteString(strings.ReplaceAll(l, "\n", "\r\n"))
+		}
+		if !strings.HasSuffix(t, "\n") && t != "" {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(".\r\n")
+		return b.String()
+	}
+
+	items, ok := s.host.Menus[selector]
+	if !ok {
+		items = []Item{{Type: grawler.ErrorMessageType, Display: "Not found: " + selector}}
+	}
+
+	var b strings.Builder
+	for _, i := range items {
+		name := i.Host
+		if name == "" {
+			name = s.name
+		}
+		h := g.Host(name)
+
+		gi := gopher.Item{
+			Type:     byte(i.Type),
+			Display:  i.Display,
+			Selector: i.Selector,
+			Hostname: h.Hostname,
+			Port:     h.Port,
+		}
+		b.WriteString(gi.String())
+		b.WriteString("\r\n")
+	}
+	b.WriteString(".\r\n")
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package grawlertest
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/blabber/grawler/gopher"
+	"github.com/blabber/grawler/grawler"
+)
+
+var testSpec = Spec{
+	"alpha": {
+		Menus: map[string][]Item{
+			"": {
+				{grawler.InformationalMessageType, "Welcome", "", ""},
+				{grawler.DirectoryType, "Beta", "/beta", "beta"},
+				{'0', "About", "/about", ""},
+			},
+		},
+		Texts: map[string]string{
+			"/about": "Hello\n.hidden\n",
+		},
+	},
+	"beta": {
+		Menus: map[string][]Item{
+			"/beta": {{grawler.DirectoryType, "Elsewhere", "", "example.com"}},
+		},
+	},
+}
+
+func TestGopherspace(t *testing.T) {
+	space, err := Start(testSpec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	c := &gopher.Client{}
+	items, err := c.Menu(context.Background(), space.Addr("alpha"), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("%d != %d", len(items), 3)
+	}
+	if a := items[1].Hostname + ":" + items[1].Port; a != space.Addr("beta") {
+		t.Errorf("%q != %q", a, space.Addr("beta"))
+	}
+
+	items, err = c.Menu(context.Background(), space.Addr("beta"), "/beta")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a := items[0].Hostname + ":" + items[0].Port; a != "example.com:70" {
+		t.Errorf("%q != %q", a, "example.com:70")
+	}
+
+	rc, err := c.Text(context.Background(), space.Addr("alpha"), "/about")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(b) != "Hello\n.hidden\n" {
+		t.Errorf("%q != %q", b, "Hello\n.hidden\n")
+	}
+
+	requests := space.Requests("alpha")
+	if len(requests) != 2 || requests[0] != "" || requests[1] != "/about" {
+		t.Errorf("%q != %q", requests, []string{"", "/about"})
+	}
+	if n := space.Name(space.Host("beta")); n != "beta" {
+		t.Errorf("%q != %q", n, "beta")
+	}
+}
+
+func TestGopherspaceDown(t *testing.T) {
+	space, err := Start(Spec{"down": {Down: true}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer space.Close()
+
+	tests := []struct {
+		c     *gopher.Client
+		class string
+	}{
+		{space.Client(), grawler.ErrorClassRefused},
+		{&gopher.Client{}, grawler.ErrorClassReset},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 2; i++ {
+			_, err := tt.c.Menu(context.Background(), space.Addr("down"), "")
+			if cl := grawler.ErrorClass(err); cl != tt.class {
+				t.Errorf("%q != %q (%v)", cl, tt.class, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package grawlertest
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/blabber/grawler/gopher"
+	"github.com/blabber/grawler/grawler"
+)
+
+// Degree is the distribution of the number of links in the menus of a
+// synthetic gopherspace.
+type Degree int
+
+// Degree distributions.
+const (
+	DegreeUniform  Degree = iota // between 0 and MaxDegree links
+	DegreePowerLaw               // few menus with many links, links prefer few hubs
+)
+
+// String returns the name of the Degree distribution.
+func (d Degree) String() string {
+	switch d {
+	case DegreeUniform:
+		return "uniform"
+	case DegreePowerLaw:
+		return "powerlaw"
+	}
+	return fmt.Sprintf("Degree(%d)", int(d))
+}
+
+// SyntheticConfig configures a synthetic gopherspace.
+type SyntheticConfig struct {
+	Hosts     int     // number of hosts
+	Menus     int     // number of menus per host, including the root menu
+	MaxDegree int     // maximum number of links per menu
+	Degree    Degree  // distribution of the number of links
+	DeadRatio float64 // ratio of hosts refusing connections
+	TrapRatio float64 // ratio of hosts with a trap menu
+	TrapDepth int     // depth of trap menus, 0 for endless traps
+	Seed      int64
+}
+
+// Synthetic is a random gopherspace described by a SyntheticConfig. Menus are
+// generated when they are requested, so the memory used does not depend on
+// the size of the gopherspace. The same SyntheticConfig always yields the same
+// gopherspace.
+//
+// The hosts are named "host<n>.example", the root menu of host 0 is the
+// starting point of a crawl and host 0 is never dead. Further menus have
+// the selectors "/<n>". Trap menus have the selectors "/trap.cgi?depth=<n>",
+// each of them links to the next deeper one, like the interactive games
+// found in the real gopherspace.
+type Synthetic struct {
+	SyntheticConfig
+}
+
+// NewSynthetic returns the Synthetic gopherspace described by c.
+func NewSynthetic(c SyntheticConfig) *Synthetic {
+	if c.Hosts < 1 {
+		c.Hosts = 1
+	}
+	if c.Menus < 1 {
+		c.Menus = 1
+	}
+	return &Synthetic{c}
+}
+
+// splitMix is a small and fast rand.Source (SplitMix64), cheap enough to be
+// created for every generated menu.
+type splitMix uint64
+
+func (s *splitMix) Uint64() uint64 {
+	*s += 0x9e3779b97f4a7c15
+	z := uint64(*s)
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *splitMix) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *splitMix) Seed(seed int64) {
+	*s = splitMix(seed)
+}
+
+// rand returns a pseudo-random source for element n of the kind, and its menu
+// m.
+func (s *Synthetic) rand(kind string, n, m int) *rand.Rand {
+	h := fnv.New64a()
+	io.WriteString(h, kind)
+	src := splitMix(h.Sum64() ^ uint64(s.Seed))
+	src.Seed(int64(src.Uint64() ^ uint64(n)))
+	src.Seed(int64(src.Uint64() ^ uint64(m)))
+	return rand.New(&src)
+}
+
+// Hostname returns the hostname of host n.
+func (s *Synthetic) Hostname(n int) string {
+	return "host" + strconv.Itoa(n) + ".example"
+}
+
+// Root returns the root menu of host 0.
+func (s *Synthetic) Root() *grawler.Resource {
+	return &grawler.Resource{
+		Host: &grawler.Host{Hostname: s.Hostname(0), Port: "70"},
+		Type: grawler.DirectoryType,
+	}
+}
+
+// Dead returns true, if host n refuses connections.
+func (s *Synthetic) Dead(n int) bool {
+	return n != 0 && s.rand("dead", n, 0).Float64() < s.DeadRatio
+}
+
+// Trap returns true, if host n has a trap menu.
+func (s *Synthetic) Trap(n int) bool {
+	return s.rand("trap", n, 0).Float64() < s.TrapRatio
+}
+
+// host returns the number of the host with the hostname, or -1 if the host is
+// not part of the gopherspace.
+func (s *Synthetic) host(hostname string) int {
+	if !strings.HasPrefix(hostname, "host") || !strings.HasSuffix(hostname, ".example") {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(hostname, "host"), ".example"))
+	if err != nil || n < 0 || n >= s.Hosts {
+		return -1
+	}
+	return n
+}
+
+// degree returns a random number of links for a menu.
+func (s *Synthetic) degree(r *rand.Rand) int {
+	if s.MaxDegree <= 0 {
+		return 0
+	}
+	if s.Degree == DegreePowerLaw {
+		return 1 + int(rand.NewZipf(r, 1.5, 1, uint64(s.MaxDegree-1)).Uint64())
+	}
+	return r.Intn(s.MaxDegree + 1)
+}
+
+// target returns a random host linked to. With DegreePowerLaw half of the
+// links point to hubs, hosts ranked by a power law.
+func (s *Synthetic) target(r *rand.Rand) int {
+	if s.Degree == DegreePowerLaw && r.Intn(2) == 0 {
+		rank := rand.NewZipf(r, 1.2, 1, uint64(s.Hosts-1)).Uint64()
+		// Scatter the hubs over the hosts.
+		return int((rank * 7919) % uint64(s.Hosts))
+	}
+	return r.Intn(s.Hosts)
+}
+
+// Menu returns the items of menu selector of host n. It returns false, if the
+// menu does not exist.
+func (s *Synthetic) Menu(n int, selector string) ([]*gopher.Item, bool) {
+	item := func(t byte, display, selector string, host int) *gopher.Item {
+		return &gopher.Item{Type: t, Display: display, Selector: selector, Hostname: s.Hostname(host), Port: "70"}
+	}
+
+	if depth, ok := s.trapDepth(selector); ok {
+		if !s.Trap(n) {
+			return nil, false
+		}
+		items := []*gopher.Item{item(gopher.TypeInfo, "You are in a maze of twisty little menus", "", n)}
+		if s.TrapDepth == 0 || depth < s.TrapDepth {
+			next := fmt.Sprintf("/trap.cgi?depth=%d", depth+1)
+			items = append(items, item(gopher.TypeDirectory, "Go deeper", next, n))
+		}
+		return items, true
+	}
+
+	menu := 0
+	if selector != "" {
+		m, err := strconv.Atoi(strings.TrimPrefix(selector, "/"))
+		if err != nil || !strings.HasPrefix(selector, "/") || m < 1 || m >= s.Menus {
+			return nil, false
+		}
+		menu = m
+	}
+
+	r := s.rand("menu", n, menu)
+	items := []*gopher.Item{
+		item(gopher.TypeInfo, fmt.Sprintf("Menu %d of %s", menu, s.Hostname(n)), "", n),
+		item(gopher.TypeText, "About", fmt.Sprintf("/about%d.txt", menu), n),
+	}
+	if menu == 0 {
+		// Chain all hosts, so most of them are reachable.
+		items = append(items, item(gopher.TypeDirectory, "Next host", "", (n+1)%s.Hosts))
+		if s.Trap(n) {
+			items = append(items, item(gopher.TypeDirectory, "Play a game", "/trap.cgi?depth=1", n))
+		}
+	}
+	for i, d := 0, s.degree(r); i < d; i++ {
+		target, m := n, r.Intn(s.Menus)
+		if r.Intn(2) == 0 {
+			target = s.target(r)
+		}
+		sel := ""
+		if m > 0 {
+			sel = "/" + strconv.Itoa(m)
+		}
+		items = append(items, item(gopher.TypeDirectory, fmt.Sprintf("Link %d", i), sel, target))
+	}
+	return items, true
+}
+
+// trapDepth returns the depth of a trap menu selector.
+func (s *Synthetic) trapDepth(selector string) (int, bool) {
+	d := strings.TrimPrefix(selector, "/trap.cgi?depth=")
+	if d == selector {
+		return 0, false
+	}
+	depth, err := strconv.Atoi(d)
+	return depth, err == nil && depth > 0
+}
+
+// Opener returns a ResourceOpener serving the gopherspace from memory. Opening
+// resources of dead hosts or hosts outside of the gopherspace fails with a
+// refused connection. Unknown menus are answered by an error menu.
+func (s *Synthetic) Opener() grawler.ResourceOpener {
+	return func(r *grawler.Resource) (io.ReadCloser, error) {
+		n := s.host(r.Hostname)
+		if n < 0 || r.Port != "70" || s.Dead(n) {
+			return nil, refused(r.Host)
+		}
+
+		items, ok := s.Menu(n, r.Selector)
+		if !ok {
+			items = []*gopher.Item{{Type: gopher.TypeError, Display: "Not found: " + r.Selector,
+				Hostname: "error.host", Port: "1"}}
+		}
+
+		var b bytes.Buffer
+		for _, i := range items {
+			b.WriteString(i.String())
+			b.WriteString("\r\n")
+		}
+		b.WriteString(".\r\n")
+		return io.NopCloser(&b), nil
+	}
+}
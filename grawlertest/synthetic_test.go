@@ -0,0 +1,152 @@
+package grawlertest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// discard is a dotfile discarding everything written.
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
+func (discard) Close() error                { return nil }
+
+func TestSyntheticDeterministic(t *testing.T) {
+	c := SyntheticConfig{Hosts: 100, Menus: 5, MaxDegree: 10, Degree: DegreePowerLaw, Seed: 7}
+	a, b := NewSynthetic(c), NewSynthetic(c)
+
+	for n := 0; n < c.Hosts; n++ {
+		for _, sel := range []string{"", "/1", "/4"} {
+			ma, _ := a.Menu(n, sel)
+			mb, _ := b.Menu(n, sel)
+			if len(ma) != len(mb) {
+				t.Fatalf("%d != %d", len(ma), len(mb))
+			}
+			for i := range ma {
+				if *ma[i] != *mb[i] {
+					t.Errorf("%v != %v", ma[i], mb[i])
+				}
+			}
+		}
+	}
+
+	c.Seed = 8
+	other := NewSynthetic(c)
+	same := true
+	for n := 0; n < c.Hosts && same; n++ {
+		ma, _ := a.Menu(n, "")
+		mo, _ := other.Menu(n, "")
+		same = len(ma) == len(mo)
+	}
+	if same {
+		t.Errorf("Different seeds yield the same gopherspace")
+	}
+}
+
+func TestSyntheticMenus(t *testing.T) {
+	s := NewSynthetic(SyntheticConfig{Hosts: 10, Menus: 3, MaxDegree: 4, TrapRatio: 1, TrapDepth: 2})
+
+	var menuTests = []struct {
+		selector string
+		exists   bool
+	}{
+		{"", true},
+		{"/2", true},
+		{"/3", false},
+		{"/0", false},
+		{"foo", false},
+		{"/trap.cgi?depth=1", true},
+		{"/trap.cgi?depth=0", false},
+	}
+	for _, tt := range menuTests {
+		if _, ok := s.Menu(1, tt.selector); ok != tt.exists {
+			t.Errorf("%q: %v != %v", tt.selector, ok, tt.exists)
+		}
+	}
+
+	items, _ := s.Menu(1, "/trap.cgi?depth=2")
+	if len(items) != 1 {
+		t.Errorf("Trap of depth %d does not end: %d items", 2, len(items))
+	}
+}
+
+func TestSyntheticDeadRatio(t *testing.T) {
+	s := NewSynthetic(SyntheticConfig{Hosts: 10000, DeadRatio: 0.3, Seed: 1})
+
+	dead := 0
+	for n := 0; n < s.Hosts; n++ {
+		if s.Dead(n) {
+			dead++
+		}
+	}
+	if s.Dead(0) {
+		t.Errorf("Host 0 is dead")
+	}
+	if dead < 2700 || dead > 3300 {
+		t.Errorf("%d dead hosts, expected about %d", dead, 3000)
+	}
+}
+
+func TestSyntheticOpener(t *testing.T) {
+	s := NewSynthetic(SyntheticConfig{Hosts: 10, DeadRatio: 1})
+	o := s.Opener()
+
+	rc, err := o(s.Root())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b, _ := io.ReadAll(rc)
+	if len(b) == 0 {
+		t.Errorf("Empty root menu")
+	}
+
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: s.Hostname(1), Port: "70"}, Type: grawler.DirectoryType}
+	if _, err := o(r); !errors.Is(err, grawler.ErrDial) {
+		t.Errorf("%v is not %v", err, grawler.ErrDial)
+	}
+}
+
+func TestSyntheticCrawl(t *testing.T) {
+	s := NewSynthetic(SyntheticConfig{
+		Hosts:     200,
+		Menus:     4,
+		MaxDegree: 6,
+		DeadRatio: 0.1,
+		TrapRatio: 0.1,
+		TrapDepth: 5,
+		Seed:      3,
+	})
+
+	stats, err := Crawl(context.Background(), s.Opener(), s.Root(), 4, discard{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	alive, traps := 0, 0
+	for n := 0; n < s.Hosts; n++ {
+		if !s.Dead(n) {
+			alive++
+			if s.Trap(n) {
+				traps++
+			}
+		}
+	}
+	// Every host is reachable through the chain of root menus, unless the
+	// chain is broken by a dead host. At most all menus and traps of the
+	// alive hosts and the roots of the dead hosts are crawled.
+	if max := alive*(s.Menus+s.TrapDepth) + s.Hosts - alive; stats.Menus > max {
+		t.Errorf("%d menus crawled, expected at most %d", stats.Menus, max)
+	}
+	if stats.Menus < s.Menus {
+		t.Errorf("%d menus crawled, expected at least %d", stats.Menus, s.Menus)
+	}
+	for r, err := range stats.Errors {
+		if !errors.Is(err, grawler.ErrDial) {
+			t.Errorf("%s: Unexpected error: %v", r, err)
+		}
+	}
+}
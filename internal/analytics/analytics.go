@@ -0,0 +1,348 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Package analytics implements algorithms ranking and clustering the nodes of
+// the server graph built by the crawler.
+//
+// All algorithms ignore self-loops. Results are deterministic, nodes are
+// processed in lexical order.
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Damping is the damping factor used by PageRank.
+const Damping = 0.85
+
+// graph is an adjacency list representation of a grawler.Graph with nodes
+// identified by their index in nodes.
+type graph struct {
+	nodes []string
+	index map[string]int
+	out   [][]int
+}
+
+// newGraph builds the adjacency lists of the references of grawler.Graph g.
+func newGraph(g *grawler.Graph) *graph {
+	a := &graph{nodes: g.SortedNodes(), index: make(map[string]int)}
+	for i, n := range a.nodes {
+		a.index[n] = i
+	}
+
+	a.out = make([][]int, len(a.nodes))
+	for _, e := range g.SortedEdges() {
+		if !g.Reference(e) {
+			continue
+		}
+		from, to := a.index[e.From], a.index[e.To]
+		a.out[from] = append(a.out[from], to)
+	}
+	return a
+}
+
+// PageRank computes the PageRank of every node of Graph g. The ranks sum up to
+// 1. The rank of nodes without outgoing edges is distributed evenly across all
+// nodes. Iteration stops after iterations rounds or when the ranks changed by
+// less than tolerance in total.
+func PageRank(g *grawler.Graph, iterations int, tolerance float64) map[string]float64 {
+	a := newGraph(g)
+	n := len(a.nodes)
+	ranks := make(map[string]float64, n)
+	if n == 0 {
+		return ranks
+	}
+
+	pr := make([]float64, n)
+	for i := range pr {
+		pr[i] = 1 / float64(n)
+	}
+
+	next := make([]float64, n)
+	for it := 0; it < iterations; it++ {
+		dangling := 0.0
+		for i, out := range a.out {
+			if len(out) == 0 {
+				dangling += pr[i]
+			}
+		}
+
+		base := (1-Damping)/float64(n) + Damping*dangling/float64(n)
+		for i := range next {
+			next[i] = base
+		}
+		for i, out := range a.out {
+			share := Damping * pr[i] / float64(len(out))
+			for _, j := range out {
+				next[j] += share
+			}
+		}
+
+		delta := 0.0
+		for i := range pr {
+			delta += math.Abs(next[i] - pr[i])
+		}
+		pr, next = next, pr
+		if delta < tolerance {
+			break
+		}
+	}
+
+	for i, node := range a.nodes {
+		ranks[node] = pr[i]
+	}
+	return ranks
+}
+
+// StronglyConnectedComponents returns the strongly connected components of
+// Graph g using Tarjan's algorithm. Every component is sorted, the components
+// are sorted by descending size and by their first node.
+func StronglyConnectedComponents(g *grawler.Graph) [][]string {
+	a := newGraph(g)
+	n := len(a.nodes)
+
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for i := range index {
+		index[i] = -1
+	}
+
+	var (
+		stack      []int
+		components [][]string
+		next       int
+	)
+
+	var strongConnect func(v int)
+	strongConnect = func(v int) {
+		index[v] = next
+		low[v] = next
+		next++
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range a.out[v] {
+			switch {
+			case index[w] < 0:
+				strongConnect(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			case onStack[w] && index[w] < low[v]:
+				low[v] = index[w]
+			}
+		}
+
+		if low[v] != index[v] {
+			return
+		}
+
+		var c []string
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			c = append(c, a.nodes[w])
+			if w == v {
+				break
+			}
+		}
+		sort.Strings(c)
+		components = append(components, c)
+	}
+
+	for v := range a.nodes {
+		if index[v] < 0 {
+			strongConnect(v)
+		}
+	}
+
+	sort.Slice(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+	return components
+}
+
+// Betweenness computes the betweenness centrality of every node of Graph g
+// using Brandes' algorithm, i.e. the number of shortest paths between other
+// nodes passing through it. The values are not normalized.
+func Betweenness(g *grawler.Graph) map[string]float64 {
+	a := newGraph(g)
+	n := len(a.nodes)
+	cb := make([]float64, n)
+
+	sigma := make([]float64, n)
+	dist := make([]int, n)
+	delta := make([]float64, n)
+	pred := make([][]int, n)
+	for s := 0; s < n; s++ {
+		for i := 0; i < n; i++ {
+			sigma[i] = 0
+			dist[i] = -1
+			delta[i] = 0
+			pred[i] = pred[i][:0]
+		}
+		sigma[s] = 1
+		dist[s] = 0
+
+		var order []int
+		queue := []int{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			order = append(order, v)
+			for _, w := range a.out[v] {
+				if dist[w] < 0 {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					pred[w] = append(pred[w], v)
+				}
+			}
+		}
+
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range pred[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				cb[w] += delta[w]
+			}
+		}
+	}
+
+	b := make(map[string]float64, n)
+	for i, node := range a.nodes {
+		b[node] = cb[i]
+	}
+	return b
+}
+
+// Reachability returns the distance in hops of every node reachable from one
+// of the seeds. Seeds not contained in Graph g are ignored, nodes not
+// reachable from any seed are not contained in the result.
+func Reachability(g *grawler.Graph, seeds []string) map[string]int {
+	a := newGraph(g)
+	dist := make(map[string]int)
+
+	var queue []int
+	for _, s := range seeds {
+		i, ok := a.index[s]
+		if !ok {
+			continue
+		}
+		if _, ok := dist[s]; !ok {
+			dist[s] = 0
+			queue = append(queue, i)
+		}
+	}
+
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range a.out[v] {
+			if _, ok := dist[a.nodes[w]]; !ok {
+				dist[a.nodes[w]] = dist[a.nodes[v]] + 1
+				queue = append(queue, w)
+			}
+		}
+	}
+	return dist
+}
+
+// NodeResult holds the analytics results of a single node.
+type NodeResult struct {
+	Node        string  `json:"node"`
+	PageRank    float64 `json:"pagerank"`
+	InDegree    int     `json:"in_degree"`
+	OutDegree   int     `json:"out_degree"`
+	Betweenness float64 `json:"betweenness"`
+	Component   int     `json:"component"` // index into Results.Components
+	Distance    int     `json:"distance"`  // hops from the nearest seed, -1 if unreachable
+	Alive       bool    `json:"alive"`
+}
+
+// Results holds the analytics results of a Graph.
+type Results struct {
+	Nodes      []NodeResult `json:"nodes"` // sorted by descending PageRank
+	Components [][]string   `json:"components"`
+	Seeds      []string     `json:"seeds"`
+	Reachable  int          `json:"reachable"`
+}
+
+// Analyze runs all analytics on Graph g. Reachability is computed from seeds.
+func Analyze(g *grawler.Graph, seeds []string) *Results {
+	pr := PageRank(g, 100, 1e-9)
+	in := g.InDegrees()
+	out := g.OutDegrees()
+	b := Betweenness(g)
+	dist := Reachability(g, seeds)
+
+	r := &Results{
+		Components: StronglyConnectedComponents(g),
+		Seeds:      seeds,
+		Reachable:  len(dist),
+	}
+
+	component := make(map[string]int)
+	for i, c := range r.Components {
+		for _, n := range c {
+			component[n] = i
+		}
+	}
+
+	for _, n := range g.SortedNodes() {
+		d, ok := dist[n]
+		if !ok {
+			d = -1
+		}
+		r.Nodes = append(r.Nodes, NodeResult{
+			Node:        n,
+			PageRank:    pr[n],
+			InDegree:    in[n],
+			OutDegree:   out[n],
+			Betweenness: b[n],
+			Component:   component[n],
+			Distance:    d,
+			Alive:       g.Alive(n),
+		})
+	}
+	sort.SliceStable(r.Nodes, func(i, j int) bool {
+		return r.Nodes[i].PageRank > r.Nodes[j].PageRank
+	})
+
+	return r
+}
+
+// Annotate adds the results as node attributes to Graph g: pagerank, indegree,
+// outdegree, betweenness, scc (the index of the strongly connected component)
+// and distance (omitted for unreachable nodes).
+func (r *Results) Annotate(g *grawler.Graph) {
+	for _, n := range r.Nodes {
+		a := grawler.Attributes{
+			"pagerank":    strconv.FormatFloat(n.PageRank, 'g', 6, 64),
+			"indegree":    strconv.Itoa(n.InDegree),
+			"outdegree":   strconv.Itoa(n.OutDegree),
+			"betweenness": strconv.FormatFloat(n.Betweenness, 'g', 6, 64),
+			"scc":         strconv.Itoa(n.Component),
+		}
+		if n.Distance >= 0 {
+			a["distance"] = strconv.Itoa(n.Distance)
+		}
+		g.AddNode(n.Node, a)
+	}
+}
@@ -0,0 +1,186 @@
+package analytics
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// testDotfile describes two clusters: a cycle a -> b -> c -> a with a bridge
+// c -> d to the cycle d -> e -> d, plus the isolated node f and a self-loop.
+var testDotfile = `strict digraph {
+	"a:70"[alive=true]
+	"b:70"[alive=true]
+	"c:70"[alive=true]
+	"d:70"[alive=true]
+	"f:70"
+	"a:70" -> "b:70"
+	"a:70" -> "a:70"
+	"b:70" -> "c:70"
+	"c:70" -> "a:70"
+	"c:70" -> "d:70"
+	"d:70" -> "e:70"
+	"e:70" -> "d:70"
+}
+`
+
+func testGraph(t *testing.T) *grawler.Graph {
+	g, err := grawler.ReadGraph(strings.NewReader(testDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return g
+}
+
+func TestPageRank(t *testing.T) {
+	g := testGraph(t)
+	pr := PageRank(g, 100, 1e-12)
+
+	sum := 0.0
+	for _, r := range pr {
+		sum += r
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Ranks do not sum up to 1: %v", sum)
+	}
+
+	if !(pr["d:70"] > pr["a:70"] && pr["a:70"] > pr["f:70"]) {
+		t.Errorf("Unexpected ranking: %v", pr)
+	}
+	if math.Abs(pr["d:70"]-pr["e:70"]) > 0.1 {
+		t.Errorf("Unexpected ranks of d and e: %v, %v", pr["d:70"], pr["e:70"])
+	}
+}
+
+func TestPageRankSymmetric(t *testing.T) {
+	g := grawler.NewGraph()
+	g.AddEdge("a:70", "b:70", nil)
+	g.AddEdge("b:70", "a:70", nil)
+
+	pr := PageRank(g, 100, 1e-12)
+	for n, r := range pr {
+		if math.Abs(r-0.5) > 1e-9 {
+			t.Errorf("Rank of %q: %v != %v", n, r, 0.5)
+		}
+	}
+}
+
+func TestPageRankSameAs(t *testing.T) {
+	g := testGraph(t)
+	expected := PageRank(g, 100, 1e-12)
+
+	g.AddEdge("a:70", "f:70", grawler.Attributes{"sameAs": "true"})
+	pr := PageRank(g, 100, 1e-12)
+	for n, r := range expected {
+		if math.Abs(pr[n]-r) > 1e-9 {
+			t.Errorf("Rank of %q: %v != %v", n, pr[n], r)
+		}
+	}
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	g := testGraph(t)
+
+	expected := [][]string{
+		{"a:70", "b:70", "c:70"},
+		{"d:70", "e:70"},
+		{"f:70"},
+	}
+	c := StronglyConnectedComponents(g)
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("%v != %v", c, expected)
+	}
+}
+
+func TestBetweenness(t *testing.T) {
+	g := grawler.NewGraph()
+	g.AddEdge("a:70", "b:70", nil)
+	g.AddEdge("b:70", "c:70", nil)
+	g.AddEdge("b:70", "b:70", nil)
+
+	expected := map[string]float64{"a:70": 0, "b:70": 1, "c:70": 0}
+	b := Betweenness(g)
+	if !reflect.DeepEqual(b, expected) {
+		t.Errorf("%v != %v", b, expected)
+	}
+
+	// Two shortest paths from a to d share the betweenness.
+	g.AddEdge("a:70", "x:70", nil)
+	g.AddEdge("x:70", "c:70", nil)
+	b = Betweenness(g)
+	if b["b:70"] != 0.5 || b["x:70"] != 0.5 {
+		t.Errorf("Unexpected betweenness: %v", b)
+	}
+}
+
+func TestReachability(t *testing.T) {
+	g := testGraph(t)
+
+	expected := map[string]int{"b:70": 0, "c:70": 1, "a:70": 2, "d:70": 2, "e:70": 3}
+	d := Reachability(g, []string{"b:70", "unknown:70"})
+	if !reflect.DeepEqual(d, expected) {
+		t.Errorf("%v != %v", d, expected)
+	}
+}
+
+func TestAnnotateSmallPageRank(t *testing.T) {
+	g := testGraph(t)
+	r := &Results{Nodes: []NodeResult{{Node: "a:70", PageRank: 9.87e-05, Betweenness: 1e-7}}}
+	r.Annotate(g)
+
+	var b strings.Builder
+	if _, err := g.WriteTo(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s := b.String(); !strings.Contains(s, `pagerank="9.87e-05"`) {
+		t.Errorf("PageRank not quoted: %s", s)
+	}
+
+	g, err := grawler.ReadGraph(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if pr := g.Nodes["a:70"]["pagerank"]; pr != "9.87e-05" {
+		t.Errorf("%q != %q", pr, "9.87e-05")
+	}
+}
+
+func TestAnalyzeAnnotate(t *testing.T) {
+	g := testGraph(t)
+	r := Analyze(g, []string{"a:70"})
+
+	if len(r.Nodes) != 6 || r.Reachable != 5 || len(r.Components) != 3 {
+		t.Fatalf("Unexpected results: %+v", r)
+	}
+	for i := 1; i < len(r.Nodes); i++ {
+		if r.Nodes[i-1].PageRank < r.Nodes[i].PageRank {
+			t.Errorf("Nodes not sorted by PageRank: %+v", r.Nodes)
+		}
+	}
+
+	r.Annotate(g)
+
+	a := g.Nodes["c:70"]
+	expected := map[string]string{
+		"alive":     "true",
+		"indegree":  "1",
+		"outdegree": "2",
+		"scc":       "0",
+		"distance":  "2",
+	}
+	for k, v := range expected {
+		if a[k] != v {
+			t.Errorf("Attribute %q: %q != %q", k, a[k], v)
+		}
+	}
+	if a["pagerank"] == "" || a["betweenness"] == "" {
+		t.Errorf("Missing attributes: %v", a)
+	}
+
+	if _, ok := g.Nodes["f:70"]["distance"]; ok {
+		t.Errorf("Unexpected distance of unreachable node: %v", g.Nodes["f:70"])
+	}
+}
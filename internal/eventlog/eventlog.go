@@ -0,0 +1,147 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Structured logging of crawl events.
+package eventlog
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	JobStarted  = "job_started"
+	JobFinished = "job_finished"
+	JobRetry    = "job_retry"  // a failed job is requeued
+	JobFailed   = "job_failed" // a failed job is given up
+	Finding     = "finding"
+	Rejected    = "rejected"
+	Status      = "status"
+	Message     = "message" // free-form log message
+)
+
+// Jobs holds the job counts of a grawler.Coordinator.
+type Jobs struct {
+	Queued   int `json:"queued"`
+	Active   int `json:"active"`
+	Finished int `json:"finished"`
+}
+
+// Event is a crawl event. Only the fields relevant for the event Type are
+// set.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       string    `json:"event"`
+	Crawler    int       `json:"crawler,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Parent     string    `json:"parent,omitempty"`
+	Duration   float64   `json:"duration,omitempty"` // in seconds
+	Bytes      int64     `json:"bytes,omitempty"`
+	Items      int       `json:"items,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	Delay      float64   `json:"delay,omitempty"` // in seconds
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Jobs       *Jobs     `json:"jobs,omitempty"`
+	Message    string    `json:"message,omitempty"`
+}
+
+// Logger logs Events. Implementations are safe for concurrent use. The Time of
+// an Event is set by the Logger if it is zero.
+type Logger interface {
+	Log(e Event)
+}
+
+// textLogger logs Events as human readable lines.
+type textLogger struct {
+	l *log.Logger
+}
+
+// NewText returns a Logger writing human readable lines using *log.Logger l.
+// Findings are not logged.
+func NewText(l *log.Logger) Logger {
+	return &textLogger{l}
+}
+
+func (t *textLogger) Log(e Event) {
+	switch e.Type {
+	case JobStarted:
+		t.l.Printf("[%d] Crawling %s", e.Crawler, e.URL)
+	case JobFinished:
+		if e.Error != "" {
+			t.l.Printf("[%d] ERR: %s", e.Crawler, e.Error)
+		}
+		t.l.Printf("[%d] Done crawling %s", e.Crawler, e.URL)
+	case JobRetry:
+		t.l.Printf("[%d] Retrying %s in %v (attempt %d failed)", e.Crawler, e.URL,
+			time.Duration(e.Delay*float64(time.Second)), e.Attempt)
+	case JobFailed:
+		t.l.Printf("[%d] Giving up %s after %d attempts", e.Crawler, e.URL, e.Attempt)
+	case Rejected:
+		if e.Error != "" {
+			t.l.Print(e.Error)
+		} else {
+			t.l.Printf("Rejected (%s): %s", e.Reason, e.URL)
+		}
+	case Status:
+		if e.Jobs != nil {
+			t.l.Printf("STATUS: Queued:%d Active:%d Finished:%d", e.Jobs.Queued,
+				e.Jobs.Active, e.Jobs.Finished)
+		}
+	case Message:
+		t.l.Print(e.Message)
+	}
+}
+
+// jsonLogger logs Events as JSON lines.
+type jsonLogger struct {
+	mtx sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewJSON returns a Logger writing every Event as a JSON object on a line of
+// its own to w.
+func NewJSON(w io.Writer) Logger {
+	return &jsonLogger{enc: json.NewEncoder(w), now: time.Now}
+}
+
+func (j *jsonLogger) Log(e Event) {
+	if e.Time.IsZero() {
+		e.Time = j.now()
+	}
+
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	j.enc.Encode(&e)
+}
+
+// messageWriter turns every line written to it into a Message event.
+type messageWriter struct {
+	l Logger
+}
+
+// MessageWriter returns an io.Writer logging every line written to it as
+// Message event using Logger l. It is meant to be used as output of the
+// standard logger, so free-form log messages end up in the event log as
+// well. The standard logger should be configured without flags then.
+func MessageWriter(l Logger) io.Writer {
+	return &messageWriter{l}
+}
+
+func (m *messageWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		m.l.Log(Event{Type: Message, Message: line})
+	}
+	return len(p), nil
+}
@@ -0,0 +1,108 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testEvents = []Event{
+	{Type: JobStarted, Crawler: 3, URL: "gopher://localhost:70/1"},
+	{Type: JobFinished, Crawler: 3, URL: "gopher://localhost:70/1", Duration: 1.5, Bytes: 42, Items: 2},
+	{Type: JobFinished, Crawler: 3, URL: "gopher://dead:70/1", Error: "connection refused", ErrorClass: "refused"},
+	{Type: Finding, Crawler: 3, URL: "gopher://localhost:70/1/dir", Parent: "localhost:70"},
+	{Type: Rejected, URL: "gopher://localhost:70/1/game.cgi?x", Reason: "blacklist"},
+	{Type: Status, Jobs: &Jobs{Queued: 0, Active: 1, Finished: 7}},
+	{Type: Message, Message: "Hello"},
+	{Type: JobRetry, Crawler: 2, URL: "gopher://slow:70/1", Attempt: 1, Delay: 1.5, Error: "i/o timeout", ErrorClass: "timeout"},
+	{Type: JobFailed, Crawler: 2, URL: "gopher://slow:70/1", Attempt: 3, Error: "i/o timeout", ErrorClass: "timeout"},
+}
+
+func TestText(t *testing.T) {
+	var b bytes.Buffer
+	l := NewText(log.New(&b, "", 0))
+	for _, e := range testEvents {
+		l.Log(e)
+	}
+
+	expected := `[3] Crawling gopher://localhost:70/1
+[3] Done crawling gopher://localhost:70/1
+[3] ERR: connection refused
+[3] Done crawling gopher://dead:70/1
+Rejected (blacklist): gopher://localhost:70/1/game.cgi?x
+STATUS: Queued:0 Active:1 Finished:7
+Hello
+[2] Retrying gopher://slow:70/1 in 1.5s (attempt 1 failed)
+[2] Giving up gopher://slow:70/1 after 3 attempts
+`
+	if b.String() != expected {
+		t.Fatalf("Unexpected output:\n%s\n!=\n%s", b.String(), expected)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b bytes.Buffer
+	l := NewJSON(&b)
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	l.(*jsonLogger).now = func() time.Time { return now }
+	for _, e := range testEvents {
+		l.Log(e)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(testEvents) {
+		t.Fatalf("Unexpected number of lines: %d != %d", len(lines), len(testEvents))
+	}
+
+	expected := []string{
+		`{"time":"2016-01-01T00:00:00Z","event":"job_started","crawler":3,"url":"gopher://localhost:70/1"}`,
+		`{"time":"2016-01-01T00:00:00Z","event":"job_finished","crawler":3,"url":"gopher://localhost:70/1","duration":1.5,"bytes":42,"items":2}`,
+	}
+	for i, e := range expected {
+		if lines[i] != e {
+			t.Errorf("%s != %s", lines[i], e)
+		}
+	}
+
+	for i, line := range lines {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Could not decode %q: %v", line, err)
+		}
+		testEvents[i].Time = now
+		if e.Type != testEvents[i].Type || e.URL != testEvents[i].URL ||
+			e.ErrorClass != testEvents[i].ErrorClass || !e.Time.Equal(now) {
+			t.Errorf("Unexpected event: %#v != %#v", e, testEvents[i])
+		}
+	}
+	if !strings.Contains(lines[5], `"jobs":{"queued":0,"active":1,"finished":7}`) {
+		t.Errorf("Unexpected status event: %s", lines[5])
+	}
+}
+
+func TestMessageWriter(t *testing.T) {
+	var b bytes.Buffer
+	l := NewJSON(&b)
+	std := log.New(MessageWriter(l), "", 0)
+
+	std.Printf("first")
+	std.Printf("second\nthird")
+
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Could not decode %q: %v", line, err)
+		}
+		if e.Type != Message {
+			t.Errorf("Unexpected event type: %q", e.Type)
+		}
+		messages = append(messages, e.Message)
+	}
+	if strings.Join(messages, ",") != "first,second,third" {
+		t.Errorf("Unexpected messages: %q", messages)
+	}
+}
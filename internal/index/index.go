@@ -0,0 +1,260 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Full-text index of crawled gopher menu items, in the spirit of Veronica.
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Doc is an indexed gopher menu item.
+type Doc struct {
+	Type     grawler.ItemType
+	Display  string
+	Selector string
+	Hostname string
+	Port     string
+}
+
+// Resource returns the *grawler.Resource referenced by Doc d.
+func (d *Doc) Resource() *grawler.Resource {
+	return &grawler.Resource{
+		Host:     &grawler.Host{Hostname: d.Hostname, Port: d.Port},
+		Type:     d.Type,
+		Selector: d.Selector,
+	}
+}
+
+// URL returns the URI string representation of the Resource referenced by Doc
+// d.
+func (d *Doc) URL() string {
+	return d.Resource().String()
+}
+
+// MenuLine returns a gopher menu line (without line terminator) referencing
+// the item described by Doc d.
+func (d *Doc) MenuLine() string {
+	return fmt.Sprintf("%v%s\t%s\t%s\t%s", d.Type, d.Display, d.Selector, d.Hostname, d.Port)
+}
+
+// Index is an in-memory inverted index of gopher menu items. Every item is
+// indexed by the terms of its display string and selector. An Index can be
+// written to a file by Save and read back as a whole by Open. An Index is safe
+// for concurrent use.
+type Index struct {
+	mtx      sync.Mutex
+	docs     []Doc
+	postings map[string][]int
+	urls     map[string]bool
+}
+
+// New creates and initializes a new, empty Index.
+func New() *Index {
+	return &Index{
+		postings: make(map[string][]int),
+		urls:     make(map[string]bool),
+	}
+}
+
+// Len returns the number of indexed items.
+func (x *Index) Len() int {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	return len(x.docs)
+}
+
+// Add adds grawler.Item i to the Index. An item that is referenced by multiple
+// menus is only indexed once, using the first display string seen. Add can be
+// used as grawler.ItemActionFunc, it never returns an error.
+func (x *Index) Add(i grawler.Item) error {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	u := i.String()
+	if x.urls[u] {
+		return nil
+	}
+	x.urls[u] = true
+
+	x.add(Doc{
+		Type:     i.Type,
+		Display:  i.Display,
+		Selector: i.Selector,
+		Hostname: i.Hostname,
+		Port:     i.Port,
+	})
+	return nil
+}
+
+// add adds Doc d to the postings. The caller has to hold x.mtx.
+func (x *Index) add(d Doc) {
+	id := len(x.docs)
+	x.docs = append(x.docs, d)
+
+	seen := make(map[string]bool)
+	for _, t := range append(Terms(d.Display), Terms(d.Selector)...) {
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		x.postings[t] = append(x.postings[t], id)
+	}
+}
+
+// Search returns all Docs matching every term of query. A term ending in "*"
+// matches all terms with the given prefix. The Docs are returned in the order
+// they have been added to the Index.
+func (x *Index) Search(query string) []Doc {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	var result []int
+	words := strings.Fields(query)
+	if len(words) == 0 {
+		return nil
+	}
+	for i, w := range words {
+		ids := x.lookup(w)
+		if i == 0 {
+			result = ids
+		} else {
+			result = intersect(result, ids)
+		}
+		if len(result) == 0 {
+			return nil
+		}
+	}
+
+	docs := make([]Doc, len(result))
+	for i, id := range result {
+		docs[i] = x.docs[id]
+	}
+	return docs
+}
+
+// lookup returns the sorted ids of all Docs matching word w. The caller has to
+// hold x.mtx.
+func (x *Index) lookup(w string) []int {
+	if strings.HasSuffix(w, "*") {
+		prefix := strings.Join(Terms(strings.TrimSuffix(w, "*")), "")
+		set := make(map[int]bool)
+		for t, ids := range x.postings {
+			if strings.HasPrefix(t, prefix) {
+				for _, id := range ids {
+					set[id] = true
+				}
+			}
+		}
+
+		ids := make([]int, 0, len(set))
+		for id := range set {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		return ids
+	}
+
+	var ids []int
+	for i, t := range Terms(w) {
+		if i == 0 {
+			ids = x.postings[t]
+		} else {
+			ids = intersect(ids, x.postings[t])
+		}
+	}
+	return ids
+}
+
+// intersect returns the intersection of the sorted int slices a and b.
+func intersect(a, b []int) []int {
+	var r []int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			r = append(r, a[i])
+			i++
+			j++
+		}
+	}
+	return r
+}
+
+// Terms splits s into lower case terms. Everything but letters and digits
+// separates terms.
+func Terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// file is the on-disk representation of an Index.
+type file struct {
+	Docs     []Doc
+	Postings map[string][]int
+}
+
+// Save writes the Index to the file name. The file is replaced atomically, so
+// Save can be called repeatedly while items are added.
+func (x *Index) Save(name string) error {
+	x.mtx.Lock()
+	defer x.mtx.Unlock()
+
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = gob.NewEncoder(f).Encode(&file{x.docs, x.postings})
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Open reads an Index previously written by Save from the file name.
+func Open(name string) (*Index, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var d file
+	if err := gob.NewDecoder(f).Decode(&d); err != nil {
+		return nil, fmt.Errorf("Could not read index %s: %v", name, err)
+	}
+
+	x := New()
+	x.docs = d.Docs
+	if d.Postings != nil {
+		x.postings = d.Postings
+	}
+	for _, doc := range x.docs {
+		x.urls[doc.URL()] = true
+	}
+	return x, nil
+}
@@ -0,0 +1,117 @@
+package index
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+var indexItems = []string{
+	"1Floodgap Systems gopher root\t/\tgopher.floodgap.com\t70",
+	"0About Veronica-2\t/v2/help/about.txt\tgopher.floodgap.com\t70",
+	"7Search Veronica-2\t/v2/vs\tgopher.floodgap.com\t70",
+	"1Gopher Project\t/\tgopherproject.org\t70",
+	"9Some binary\t/files/gopher.tar.gz\texample.com\t7070",
+	"1Floodgap Systems duplicate\t/\tgopher.floodgap.com\t70",
+}
+
+func newTestIndex(t *testing.T) *Index {
+	x := New()
+	for _, l := range indexItems {
+		i, err := grawler.NewItemFromGopherLine(l)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		x.Add(*i)
+	}
+	return x
+}
+
+var searchTests = []struct {
+	query    string
+	expected []string
+}{
+	{"veronica", []string{
+		"gopher://gopher.floodgap.com:70/0/v2/help/about.txt",
+		"gopher://gopher.floodgap.com:70/7/v2/vs",
+	}},
+	{"VERONICA about", []string{
+		"gopher://gopher.floodgap.com:70/0/v2/help/about.txt",
+	}},
+	{"gopher", []string{
+		"gopher://gopher.floodgap.com:70/1",
+		"gopher://gopherproject.org:70/1",
+		"gopher://example.com:7070/9/files/gopher.tar.gz",
+	}},
+	{"gopher*", []string{
+		"gopher://gopher.floodgap.com:70/1",
+		"gopher://gopherproject.org:70/1",
+		"gopher://example.com:7070/9/files/gopher.tar.gz",
+	}},
+	{"tar.gz", []string{
+		"gopher://example.com:7070/9/files/gopher.tar.gz",
+	}},
+	{"duplicate", nil},
+	{"veronica nonexistent", nil},
+	{"", nil},
+}
+
+func TestSearch(t *testing.T) {
+	x := newTestIndex(t)
+
+	if x.Len() != len(indexItems)-1 {
+		t.Fatalf("Unexpected number of indexed items: %d != %d", x.Len(), len(indexItems)-1)
+	}
+
+	for _, tt := range searchTests {
+		var urls []string
+		for _, d := range x.Search(tt.query) {
+			urls = append(urls, d.URL())
+		}
+		if strings.Join(urls, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("Search %q: %q != %q", tt.query, urls, tt.expected)
+		}
+	}
+}
+
+func TestDocMenuLine(t *testing.T) {
+	x := newTestIndex(t)
+
+	d := x.Search("binary")
+	if len(d) != 1 {
+		t.Fatalf("Unexpected search result: %v", d)
+	}
+	if d[0].MenuLine() != indexItems[4] {
+		t.Errorf("%q != %q", d[0].MenuLine(), indexItems[4])
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	x := newTestIndex(t)
+	name := filepath.Join(t.TempDir(), "grawler.idx")
+
+	if err := x.Save(name); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	y, err := Open(name)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if y.Len() != x.Len() {
+		t.Fatalf("Unexpected number of indexed items: %d != %d", y.Len(), x.Len())
+	}
+	for _, tt := range searchTests {
+		if len(y.Search(tt.query)) != len(tt.expected) {
+			t.Errorf("Search %q after reopening: %v", tt.query, y.Search(tt.query))
+		}
+	}
+
+	i, _ := grawler.NewItemFromGopherLine(indexItems[0])
+	y.Add(*i)
+	if y.Len() != x.Len() {
+		t.Errorf("Known item indexed again after reopening")
+	}
+}
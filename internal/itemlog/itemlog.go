@@ -0,0 +1,323 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Package itemlog writes and reads logs of the items found by the crawler.
+package itemlog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Supported item log formats.
+const (
+	FormatURL   = "url"   // one URL per line
+	FormatJSONL = "jsonl" // one JSON object per line
+	FormatCSV   = "csv"   // comma separated values with a header line
+)
+
+// Formats lists all supported item log formats.
+var Formats = []string{FormatURL, FormatJSONL, FormatCSV}
+
+// csvHeader names the columns of the CSV format.
+var csvHeader = []string{
+	"url", "type", "type_name", "display", "host", "port", "selector",
+	"parent", "plus", "discovered",
+}
+
+// Record is a single entry of the item log.
+type Record struct {
+	URL        string    `json:"url"`
+	Type       string    `json:"type"`
+	TypeName   string    `json:"type_name"`
+	Display    string    `json:"display"`
+	Hostname   string    `json:"host"`
+	Port       string    `json:"port"`
+	Selector   string    `json:"selector"`
+	Parent     string    `json:"parent,omitempty"`
+	Plus       bool      `json:"plus"`
+	Discovered time.Time `json:"discovered"`
+}
+
+// NewRecord creates the Record of grawler.Item i discovered at time t. An error
+// is returned if the URL of the item can not be assembled.
+func NewRecord(i grawler.Item, t time.Time) (Record, error) {
+	u, err := i.TryString()
+	if err != nil {
+		return Record{}, err
+	}
+
+	r := Record{
+		URL:        u,
+		Type:       i.Type.String(),
+		TypeName:   i.Type.Name(),
+		Display:    i.Display,
+		Hostname:   i.Hostname,
+		Port:       i.Port,
+		Selector:   i.Selector,
+		Plus:       i.Plus,
+		Discovered: t.UTC(),
+	}
+	if i.Parent != nil {
+		r.Parent = i.Parent.String()
+	}
+	return r, nil
+}
+
+// csvRecord returns the CSV columns of Record r.
+func (r Record) csvRecord() []string {
+	return []string{
+		r.URL, r.Type, r.TypeName, r.Display, r.Hostname, r.Port,
+		r.Selector, r.Parent, strconv.FormatBool(r.Plus),
+		r.Discovered.Format(time.RFC3339Nano),
+	}
+}
+
+// Writer writes Records in one of the supported formats. Output is buffered, so
+// Flush or Close has to be called when done. A Writer is safe for concurrent
+// use.
+type Writer struct {
+	// OnError is called for errors that can not be returned to the caller,
+	// e.g. by Action. May be nil.
+	OnError func(error)
+
+	format string
+	w      io.Writer
+	buf    *bufio.Writer
+	csv    *csv.Writer
+	enc    *json.Encoder
+	now    func() time.Time
+	mtx    sync.Mutex
+}
+
+// NewWriter creates a new Writer writing Records in format to w. An error is
+// returned if format is not supported.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	buf := bufio.NewWriter(w)
+	iw := &Writer{format: format, w: w, buf: buf, now: time.Now}
+
+	switch format {
+	case FormatURL:
+	case FormatJSONL:
+		iw.enc = json.NewEncoder(buf)
+		iw.enc.SetEscapeHTML(false)
+	case FormatCSV:
+		iw.csv = csv.NewWriter(buf)
+		if err := iw.csv.Write(csvHeader); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported item log format: %q", format)
+	}
+
+	return iw, nil
+}
+
+// Write writes Record r.
+func (w *Writer) Write(r Record) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	switch {
+	case w.enc != nil:
+		return w.enc.Encode(r)
+	case w.csv != nil:
+		return w.csv.Write(r.csvRecord())
+	default:
+		_, err := fmt.Fprintf(w.buf, "%s\n", r.URL)
+		return err
+	}
+}
+
+// Action is a grawler.ItemActionFunc writing a Record of every item. Errors are
+// reported using OnError and do not stop the crawler.
+func (w *Writer) Action(i grawler.Item) error {
+	r, err := NewRecord(i, w.now())
+	if err == nil {
+		err = w.Write(r)
+	}
+	if err != nil && w.OnError != nil {
+		w.OnError(err)
+	}
+	return nil
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	return w.buf.Flush()
+}
+
+// Close flushes the Writer and closes the underlying io.Writer, if it is an
+// io.Closer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if c, ok := w.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Reader reads Records from an item log. The format of the log is detected
+// automatically. Records read from logs in the url format carry only the URL
+// and the fields derived from it.
+type Reader struct {
+	r      *bufio.Reader
+	format string
+	dec    *json.Decoder
+	csv    *csv.Reader
+}
+
+// NewReader creates a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// detect detects the format of the item log.
+func (r *Reader) detect() error {
+	b, err := r.r.Peek(1)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case b[0] == '{':
+		r.format = FormatJSONL
+		r.dec = json.NewDecoder(r.r)
+	default:
+		h, err := r.r.Peek(len(csvHeader[0]) + 1)
+		if err == nil && string(h) == csvHeader[0]+"," {
+			r.format = FormatCSV
+			r.csv = csv.NewReader(r.r)
+			r.csv.FieldsPerRecord = len(csvHeader)
+			if _, err := r.csv.Read(); err != nil {
+				return err
+			}
+		} else {
+			r.format = FormatURL
+		}
+	}
+	return nil
+}
+
+// Read reads the next Record. io.EOF is returned if there are no more Records.
+func (r *Reader) Read() (Record, error) {
+	if r.format == "" {
+		if err := r.detect(); err != nil {
+			return Record{}, err
+		}
+	}
+
+	switch r.format {
+	case FormatJSONL:
+		var rec Record
+		err := r.dec.Decode(&rec)
+		return rec, err
+	case FormatCSV:
+		c, err := r.csv.Read()
+		if err != nil {
+			return Record{}, err
+		}
+		return parseCSVRecord(c)
+	default:
+		for {
+			l, err := r.r.ReadString('\n')
+			l = strings.TrimSpace(l)
+			if l != "" {
+				return parseURL(l)
+			}
+			if err != nil {
+				return Record{}, err
+			}
+		}
+	}
+}
+
+// parseCSVRecord parses the CSV columns c.
+func parseCSVRecord(c []string) (Record, error) {
+	plus, err := strconv.ParseBool(c[8])
+	if err != nil {
+		return Record{}, fmt.Errorf("Could not parse Gopher+ flag: %v", err)
+	}
+	t, err := time.Parse(time.RFC3339Nano, c[9])
+	if err != nil {
+		return Record{}, fmt.Errorf("Could not parse discovery time: %v", err)
+	}
+
+	return Record{
+		URL:        c[0],
+		Type:       c[1],
+		TypeName:   c[2],
+		Display:    c[3],
+		Hostname:   c[4],
+		Port:       c[5],
+		Selector:   c[6],
+		Parent:     c[7],
+		Plus:       plus,
+		Discovered: t,
+	}, nil
+}
+
+// parseURL parses a gopher URL as written by grawler.Resource.TryString.
+func parseURL(s string) (Record, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Record{}, err
+	}
+	if u.Scheme != "gopher" {
+		return Record{}, fmt.Errorf("Not a gopher URL: %q", s)
+	}
+
+	r := Record{
+		URL:      s,
+		Type:     string(grawler.DirectoryType),
+		Hostname: u.Hostname(),
+		Port:     u.Port(),
+	}
+	if p := u.Path; len(p) > 1 {
+		r.Type = p[1:2]
+		r.Selector = p[2:]
+	}
+	r.TypeName = grawler.ItemType(r.Type[0]).Name()
+	return r, nil
+}
+
+// ReadAll reads all Records from r.
+func ReadAll(r io.Reader) ([]Record, error) {
+	ir := NewReader(r)
+
+	var records []Record
+	for {
+		rec, err := ir.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+}
@@ -0,0 +1,195 @@
+package itemlog
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+var testTime = time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func testItem() grawler.Item {
+	parent := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: grawler.DirectoryType, Selector: ""}
+	return grawler.Item{
+		Resource: grawler.Resource{Host: &grawler.Host{Hostname: "example.com", Port: "7070"}, Type: '0', Selector: "/a,b.txt"},
+		Display:  "A \"quoted\" file",
+		Plus:     true,
+		Parent:   parent,
+		Line:     3,
+	}
+}
+
+var writerTests = []struct {
+	format   string
+	expected string
+}{
+	{FormatURL, "gopher://example.com:7070/0/a,b.txt\n"},
+	{FormatJSONL, `{"url":"gopher://example.com:7070/0/a,b.txt","type":"0","type_name":"text",` +
+		`"display":"A \"quoted\" file","host":"example.com","port":"7070","selector":"/a,b.txt",` +
+		`"parent":"gopher://localhost:70/1","plus":true,"discovered":"2016-01-02T03:04:05Z"}` + "\n"},
+	{FormatCSV, "url,type,type_name,display,host,port,selector,parent,plus,discovered\n" +
+		`"gopher://example.com:7070/0/a,b.txt",0,text,"A ""quoted"" file",example.com,7070,"/a,b.txt",` +
+		"gopher://localhost:70/1,true,2016-01-02T03:04:05Z\n"},
+}
+
+func TestWriter(t *testing.T) {
+	for _, tt := range writerTests {
+		var b bytes.Buffer
+		w, err := NewWriter(&b, tt.format)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w.now = func() time.Time { return testTime }
+
+		if err := w.Action(testItem()); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+
+		if b.String() != tt.expected {
+			t.Errorf("%q != %q", b.String(), tt.expected)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	expected, err := NewRecord(testItem(), testTime)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, tt := range writerTests {
+		var b bytes.Buffer
+		w, err := NewWriter(&b, tt.format)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		w.Write(expected)
+		w.Write(expected)
+		w.Flush()
+
+		records, err := ReadAll(&b)
+		if err != nil {
+			t.Errorf("Unexpected error reading %s: %v", tt.format, err)
+			continue
+		}
+		if len(records) != 2 {
+			t.Errorf("%s: %d != %d", tt.format, len(records), 2)
+			continue
+		}
+
+		e := expected
+		if tt.format == FormatURL {
+			e = Record{
+				URL:      e.URL,
+				Type:     e.Type,
+				TypeName: e.TypeName,
+				Hostname: e.Hostname,
+				Port:     e.Port,
+				Selector: e.Selector,
+			}
+		}
+		for _, r := range records {
+			if r != e {
+				t.Errorf("%s: %v != %v", tt.format, r, e)
+			}
+		}
+	}
+}
+
+func TestReaderEmpty(t *testing.T) {
+	records, err := ReadAll(strings.NewReader(""))
+	if err != nil || len(records) != 0 {
+		t.Errorf("Unexpected result: %v, %v", records, err)
+	}
+}
+
+func TestWriterUnsupportedFormat(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "xml")
+	if err == nil {
+		t.Errorf("Expected error for unsupported format")
+	}
+}
+
+func TestWriterRootParent(t *testing.T) {
+	i := testItem()
+	i.Parent = nil
+
+	r, err := NewRecord(i, testTime)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Parent != "" {
+		t.Errorf("%q != %q", r.Parent, "")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWriterOnError(t *testing.T) {
+	w, err := NewWriter(failingWriter{}, FormatURL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var reported error
+	w.OnError = func(err error) { reported = err }
+
+	i := testItem()
+	i.Selector = "\x7f"
+	if err := w.Action(i); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if reported == nil {
+		t.Errorf("Expected error to be reported")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, FormatJSONL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := 0; m < 100; m++ {
+				w.Action(testItem())
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 1000 {
+		t.Errorf("%d != %d", len(lines), 1000)
+	}
+	for _, l := range lines {
+		if !strings.HasPrefix(l, "{") || !strings.HasSuffix(l, "}") {
+			t.Errorf("Malformed line: %q", l)
+			break
+		}
+	}
+}
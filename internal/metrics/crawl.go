@@ -0,0 +1,115 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package metrics
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Reasons for rejected findings.
+const (
+	RejectedBlacklist = "blacklist"
+	RejectedDuplicate = "duplicate"
+)
+
+// Crawl holds the metrics describing a running crawl.
+type Crawl struct {
+	*Registry
+
+	Queued         *Gauge
+	Active         *Gauge
+	Finished       *Gauge
+	ActiveCrawlers *Gauge
+	FetchLatency   *Histogram
+	BytesRead      *Counter
+	Errors         *CounterVec
+	Retries        *Counter
+	Findings       *Counter
+	Rejected       *CounterVec
+}
+
+// NewCrawl creates the metrics of a crawl and registers them in a new Registry.
+func NewCrawl() *Crawl {
+	c := &Crawl{
+		Registry:       NewRegistry(),
+		Queued:         new(Gauge),
+		Active:         new(Gauge),
+		Finished:       new(Gauge),
+		ActiveCrawlers: new(Gauge),
+		FetchLatency:   NewHistogram(0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60),
+		BytesRead:      new(Counter),
+		Errors:         NewCounterVec("class"),
+		Retries:        new(Counter),
+		Findings:       new(Counter),
+		Rejected:       NewCounterVec("reason"),
+	}
+
+	c.Register("grawler_jobs_queued", "Number of queued jobs.", c.Queued)
+	c.Register("grawler_jobs_active", "Number of jobs being crawled.", c.Active)
+	c.Register("grawler_jobs_finished", "Number of finished jobs.", c.Finished)
+	c.Register("grawler_crawlers_active", "Number of crawlers working on a job.", c.ActiveCrawlers)
+	c.Register("grawler_fetch_duration_seconds", "Time from opening a resource until it has been read completely.", c.FetchLatency)
+	c.Register("grawler_read_bytes_total", "Number of bytes read from resources.", c.BytesRead)
+	c.Register("grawler_errors_total", "Number of failed jobs by error class.", c.Errors)
+	c.Register("grawler_retries_total", "Number of failed jobs requeued to be retried.", c.Retries)
+	c.Register("grawler_findings_total", "Number of findings reported by crawlers, use rate() to get findings per second.", c.Findings)
+	c.Register("grawler_findings_rejected_total", "Number of findings not queued by reason.", c.Rejected)
+
+	return c
+}
+
+// SetJobs sets the job gauges to the numbers of queued, active and finished
+// jobs, as returned by grawler.Coordinator.Counts.
+func (c *Crawl) SetJobs(queued, active, finished int) {
+	c.Queued.Set(float64(queued))
+	c.Active.Set(float64(active))
+	c.Finished.Set(float64(finished))
+}
+
+// Error counts err by its grawler.ErrorClass. A nil err is ignored.
+func (c *Crawl) Error(err error) {
+	if err != nil {
+		c.Errors.With(grawler.ErrorClass(err)).Inc()
+	}
+}
+
+// Opener returns a grawler.ResourceOpener that opens resources using
+// grawler.ResourceOpener o and measures the fetch latency and the bytes read.
+func (c *Crawl) Opener(o grawler.ResourceOpener) grawler.ResourceOpener {
+	return func(r *grawler.Resource) (io.ReadCloser, error) {
+		start := time.Now()
+		rc, err := o(r)
+		if err != nil {
+			return nil, err
+		}
+		return &measuringReadCloser{rc: rc, c: c, start: start}, nil
+	}
+}
+
+type measuringReadCloser struct {
+	rc     io.ReadCloser
+	c      *Crawl
+	start  time.Time
+	closed atomic.Bool
+}
+
+func (m *measuringReadCloser) Read(p []byte) (int, error) {
+	n, err := m.rc.Read(p)
+	m.c.BytesRead.Add(uint64(n))
+	return n, err
+}
+
+func (m *measuringReadCloser) Close() error {
+	if !m.closed.Swap(true) {
+		m.c.FetchLatency.Observe(time.Since(m.start).Seconds())
+	}
+	return m.rc.Close()
+}
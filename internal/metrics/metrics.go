@@ -0,0 +1,251 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Minimal metrics exposed in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is implemented by all metric types. write writes the samples of the
+// metric named name to w.
+type metric interface {
+	typ() string
+	write(w io.Writer, name string)
+}
+
+// Counter is a monotonically increasing value. It is safe for concurrent use.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc increments the Counter by one.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add increments the Counter by n.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns the current value of the Counter.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+func (c *Counter) typ() string { return "counter" }
+
+func (c *Counter) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %d\n", name, c.Value())
+}
+
+// Gauge is a value that can go up and down. It is safe for concurrent use.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets the Gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add adds v, which may be negative, to the Gauge.
+func (g *Gauge) Add(v float64) {
+	for {
+		old := g.bits.Load()
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if g.bits.CompareAndSwap(old, n) {
+			return
+		}
+	}
+}
+
+// Value returns the current value of the Gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (g *Gauge) typ() string { return "gauge" }
+
+func (g *Gauge) write(w io.Writer, name string) {
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(g.Value()))
+}
+
+// Histogram counts observations in cumulative buckets. It is safe for
+// concurrent use.
+type Histogram struct {
+	mtx     sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogram creates a new Histogram with the given upper bucket bounds. The
+// bounds have to be sorted in increasing order, a +Inf bucket is added
+// implicitly.
+func NewHistogram(bounds ...float64) *Histogram {
+	return &Histogram{
+		bounds:  bounds,
+		buckets: make([]uint64, len(bounds)),
+	}
+}
+
+// Observe adds observation v to the Histogram.
+func (h *Histogram) Observe(v float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for i, b := range h.bounds {
+		if v <= b {
+			h.buckets[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) typ() string { return "histogram" }
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for i, b := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, formatFloat(b), h.buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// CounterVec is a set of Counters distinguished by the value of a single
+// label. It is safe for concurrent use.
+type CounterVec struct {
+	label    string
+	mtx      sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewCounterVec creates a new CounterVec using label as label name.
+func NewCounterVec(label string) *CounterVec {
+	return &CounterVec{
+		label:    label,
+		counters: make(map[string]*Counter),
+	}
+}
+
+// With returns the Counter for label value v, creating it if necessary.
+func (c *CounterVec) With(v string) *Counter {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	counter, ok := c.counters[v]
+	if !ok {
+		counter = new(Counter)
+		c.counters[v] = counter
+	}
+	return counter
+}
+
+func (c *CounterVec) typ() string { return "counter" }
+
+func (c *CounterVec) write(w io.Writer, name string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	values := make([]string, 0, len(c.counters))
+	for v := range c.counters {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", name, c.label, quoteLabel(v), c.counters[v].Value())
+	}
+}
+
+// quoteLabel quotes a label value as required by the text exposition format.
+func quoteLabel(v string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Registry holds named metrics. It is safe for concurrent use.
+type Registry struct {
+	mtx     sync.Mutex
+	names   []string
+	help    map[string]string
+	metrics map[string]metric
+}
+
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		help:    make(map[string]string),
+		metrics: make(map[string]metric),
+	}
+}
+
+// Register registers metric m, which has to be a *Counter, *Gauge, *Histogram
+// or *CounterVec, using name and help text help. Registering a name twice
+// panics.
+func (r *Registry) Register(name, help string, m metric) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metric registered twice: %s", name))
+	}
+	r.names = append(r.names, name)
+	r.help[name] = help
+	r.metrics[name] = m
+}
+
+// WriteTo writes all registered metrics to w in the Prometheus text exposition
+// format. Metrics are written in the order they have been registered.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var b bytes.Buffer
+	for _, name := range r.names {
+		m := r.metrics[name]
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, r.help[name])
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, m.typ())
+		m.write(&b, name)
+	}
+	return b.WriteTo(w)
+}
+
+// Handler returns a http.Handler serving the registered metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+func TestRegistryWriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	c := new(Counter)
+	c.Add(3)
+	c.Inc()
+	r.Register("test_total", "A counter.", c)
+
+	g := new(Gauge)
+	g.Set(1.5)
+	g.Add(-0.5)
+	r.Register("test_gauge", "A gauge.", g)
+
+	h := NewHistogram(1, 5)
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(10)
+	r.Register("test_seconds", "A histogram.", h)
+
+	v := NewCounterVec("class")
+	v.With("timeout").Inc()
+	v.With("timeout").Inc()
+	v.With(`a "quoted" value`).Inc()
+	r.Register("test_errors_total", "A counter vector.", v)
+
+	expected := `# HELP test_total A counter.
+# TYPE test_total counter
+test_total 4
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 1
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{le="1"} 1
+test_seconds_bucket{le="5"} 2
+test_seconds_bucket{le="+Inf"} 3
+test_seconds_sum 13.5
+test_seconds_count 3
+# HELP test_errors_total A counter vector.
+# TYPE test_errors_total counter
+test_errors_total{class="a \"quoted\" value"} 1
+test_errors_total{class="timeout"} 2
+`
+
+	var b bytes.Buffer
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.String() != expected {
+		t.Fatalf("Unexpected output:\n%s\n!=\n%s", b.String(), expected)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Registering a metric twice did not panic")
+		}
+	}()
+
+	r := NewRegistry()
+	r.Register("test_total", "A counter.", new(Counter))
+	r.Register("test_total", "A counter.", new(Counter))
+}
+
+type stringReadCloser struct {
+	*strings.Reader
+}
+
+func (stringReadCloser) Close() error {
+	return nil
+}
+
+func TestCrawlHandler(t *testing.T) {
+	c := NewCrawl()
+
+	o := c.Opener(func(r *grawler.Resource) (io.ReadCloser, error) {
+		return stringReadCloser{strings.NewReader("1Dir\t/dir\tlocalhost\t70\r\n.\r\n")}, nil
+	})
+	findings := make(chan *grawler.CrawlFinding, 1)
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""}
+	if err := grawler.ResourceCrawler(o, r, findings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	c.SetJobs(5, 2, 10)
+	c.ActiveCrawlers.Add(2)
+	c.Findings.Inc()
+	c.Rejected.With(RejectedBlacklist).Inc()
+	c.Error(errors.New("some error"))
+	c.Error(nil)
+
+	s := httptest.NewServer(c.Handler())
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type: %q", ct)
+	}
+	for _, e := range []string{
+		"grawler_jobs_queued 5\n",
+		"grawler_jobs_active 2\n",
+		"grawler_jobs_finished 10\n",
+		"grawler_crawlers_active 2\n",
+		"grawler_fetch_duration_seconds_count 1\n",
+		"grawler_read_bytes_total 27\n",
+		"grawler_errors_total{class=\"other\"} 1\n",
+		"grawler_findings_total 1\n",
+		"grawler_findings_rejected_total{reason=\"blacklist\"} 1\n",
+	} {
+		if !strings.Contains(string(body), e) {
+			t.Errorf("%q not found in metrics:\n%s", e, body)
+		}
+	}
+}
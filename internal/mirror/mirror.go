@@ -0,0 +1,414 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Mirroring of crawled gopher resources to a local directory tree.
+package mirror
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// ManifestName is the name of the manifest file in the root directory of a
+// Mirror.
+const ManifestName = "manifest.json"
+
+// MenuName is the file name used to store a menu inside the directory derived
+// from its selector.
+const MenuName = ".gophermap"
+
+// DefaultMaxBytes is the default maximum size of the items fetched by the
+// ItemAction of a Mirror.
+const DefaultMaxBytes = 64 << 20
+
+// maxPartLength is the maximum length of a sanitized path component, well
+// below the limits of common file systems.
+const maxPartLength = 128
+
+// Entry describes a file stored in a Mirror.
+type Entry struct {
+	URL      string
+	Type     string
+	Size     int64
+	SHA256   string
+	Fetched  time.Time // last time the resource has been fetched
+	Modified time.Time // last time the content of the file changed
+}
+
+// Manifest maps the paths of all files stored in a Mirror, relative to its
+// root directory, to their Entries.
+type Manifest map[string]*Entry
+
+// ReadManifest reads the manifest of the Mirror in directory root. A missing
+// manifest yields an empty Manifest.
+func ReadManifest(root string) (Manifest, error) {
+	m, err := ReadManifestFile(filepath.Join(root, ManifestName))
+	if os.IsNotExist(err) {
+		return make(Manifest), nil
+	}
+	return m, err
+}
+
+// ReadManifestFile reads the manifest file name, e.g. a copy of the manifest of
+// a previous crawl.
+func ReadManifestFile(name string) (Manifest, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m := make(Manifest)
+	err = json.Unmarshal(b, &m)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read manifest %s: %v", name, err)
+	}
+	return m, nil
+}
+
+// ChangedMenus returns the sorted URLs of all menus that are part of Manifest
+// old and Manifest m, but whose content differs.
+func (m Manifest) ChangedMenus(old Manifest) []string {
+	var changed []string
+	for p, e := range m {
+		o, ok := old[p]
+		if ok && e.Type == grawler.DirectoryType.String() && o.SHA256 != e.SHA256 {
+			changed = append(changed, e.URL)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Mirror saves gopher resources to a directory tree. Every resource is stored
+// as host_port/selector-derived-path/type-derived-name. A Mirror is safe for
+// concurrent use.
+//
+// Storing a resource is idempotent: files whose content did not change are not
+// rewritten.
+type Mirror struct {
+	// OnError is called for errors that can not be returned to the caller,
+	// e.g. when a resource opened by Opener could not be stored. May be nil.
+	OnError func(error)
+
+	// MaxBytes is the maximum size of the items fetched by ItemAction, 0
+	// for no limit. Larger items are not stored.
+	MaxBytes int64
+
+	root     string
+	now      func() time.Time
+	mtx      sync.Mutex
+	manifest Manifest
+	fetched  map[string]bool
+}
+
+// Open opens the Mirror in directory root. The directory is created if
+// necessary, an existing manifest is read.
+func Open(root string) (*Mirror, error) {
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := ReadManifest(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mirror{
+		MaxBytes: DefaultMaxBytes,
+		root:     root,
+		now:      time.Now,
+		manifest: m,
+		fetched:  make(map[string]bool),
+	}, nil
+}
+
+// Path returns the path of the file storing *grawler.Resource r, relative to
+// the root directory of the Mirror.
+//
+// The selector is split at slashes and every part is sanitized: characters
+// that are unsafe in file names are replaced by underscores, long parts are
+// shortened and a short hash of the original part is appended to keep distinct
+// selectors apart. The resource is stored in the directory derived from its
+// selector, in a file named after its item type: MenuName for menus, e.g. ".0"
+// for text files. Sanitized parts never start with a dot, so the files of
+// resources never collide with the directories of others.
+func Path(r *grawler.Resource) string {
+	parts := []string{sanitize(strings.ToLower(r.Hostname)) + "_" + sanitize(r.Port)}
+	for _, p := range strings.Split(r.Selector, "/") {
+		if p != "" {
+			parts = append(parts, sanitize(p))
+		}
+	}
+	parts = append(parts, typeName(r.Type))
+
+	return filepath.Join(parts...)
+}
+
+// typeName returns the name of the file storing a resource of ItemType t.
+func typeName(t grawler.ItemType) string {
+	switch {
+	case t == grawler.DirectoryType:
+		return MenuName
+	case t >= '0' && t <= '9' || t >= 'a' && t <= 'z' || t >= 'A' && t <= 'Z':
+		return "." + t.String()
+	}
+	return fmt.Sprintf(".%02x", byte(t))
+}
+
+// sanitize replaces all characters of s that are unsafe in file names and
+// shortens s to maxPartLength. If s had to be changed, a hash of s is appended.
+func sanitize(s string) string {
+	safe := []rune(s)
+	changed := false
+	for i, c := range safe {
+		if !(c == '_' || c == '-' || c == '.' || c == '+' || c == ',' || c == '=' ||
+			c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			safe[i] = '_'
+			changed = true
+		}
+	}
+
+	r := string(safe)
+	if strings.HasPrefix(r, ".") {
+		r = "_" + r
+		changed = true
+	}
+	if len(r) > maxPartLength {
+		r = r[:maxPartLength-9]
+		changed = true
+	}
+	if changed {
+		r = fmt.Sprintf("%s_%x", r, sha1.Sum([]byte(s)))[:len(r)+9]
+	}
+	return r
+}
+
+// Store stores content as *grawler.Resource r. The file is only written, if it
+// does not exist yet or its content changed. Store reports whether the file
+// has been written.
+func (m *Mirror) Store(r *grawler.Resource, content []byte) (bool, error) {
+	p := Path(r)
+	sum := fmt.Sprintf("%x", sha256.Sum256(content))
+	now := m.now()
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.fetched[r.String()] = true
+
+	name := filepath.Join(m.root, p)
+	e, ok := m.manifest[p]
+	if ok && e.SHA256 == sum {
+		if fi, err := os.Stat(name); err == nil && fi.Size() == int64(len(content)) {
+			e.Fetched = now
+			return false, nil
+		}
+	}
+
+	err := writeFile(name, content)
+	if err != nil {
+		return false, err
+	}
+
+	m.manifest[p] = &Entry{
+		URL:      r.String(),
+		Type:     r.Type.String(),
+		Size:     int64(len(content)),
+		SHA256:   sum,
+		Fetched:  now,
+		Modified: now,
+	}
+	return true, nil
+}
+
+// writeFile atomically replaces the file name with content, creating parent
+// directories as needed.
+func writeFile(name string, content []byte) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(content)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Close writes the manifest of the Mirror.
+func (m *Mirror) Close() error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	b, err := json.MarshalIndent(m.manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.root, ManifestName), append(b, '\n'))
+}
+
+func (m *Mirror) error(err error) {
+	if m.OnError != nil {
+		m.OnError(err)
+	}
+}
+
+// Opener returns a grawler.ResourceOpener that opens resources using
+// grawler.ResourceOpener o and stores everything read from them in the Mirror
+// when they are closed. Resources are only stored if they have been read
+// without errors up to their end or the terminating "." line, so menus
+// truncated by the crawler are not stored.
+func (m *Mirror) Opener(o grawler.ResourceOpener) grawler.ResourceOpener {
+	return func(r *grawler.Resource) (io.ReadCloser, error) {
+		rc, err := o(r)
+		if err != nil {
+			return nil, err
+		}
+		return &teeReadCloser{rc: rc, m: m, r: r}, nil
+	}
+}
+
+// teeReadCloser records everything read from rc and stores it in Mirror m on
+// Close.
+type teeReadCloser struct {
+	rc  io.ReadCloser
+	m   *Mirror
+	r   *grawler.Resource
+	buf bytes.Buffer
+	eof bool
+	err error
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	t.buf.Write(p[:n])
+	switch {
+	case err == io.EOF:
+		t.eof = true
+	case err != nil:
+		t.err = err
+	}
+	return n, err
+}
+
+// RemoteAddr returns the remote address of rc, if it provides a RemoteAddr
+// method like net.Conn does, or nil.
+func (t *teeReadCloser) RemoteAddr() net.Addr {
+	if a, ok := t.rc.(interface{ RemoteAddr() net.Addr }); ok {
+		return a.RemoteAddr()
+	}
+	return nil
+}
+
+func (t *teeReadCloser) Close() error {
+	err := t.rc.Close()
+	if t.err != nil || !t.eof && !terminated(t.buf.Bytes()) {
+		return err
+	}
+
+	_, serr := t.m.Store(t.r, t.buf.Bytes())
+	if serr != nil {
+		serr = fmt.Errorf("Could not mirror %v: %v", t.r, serr)
+		t.m.error(serr)
+		if err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// terminated returns true, if b contains the terminating "." line of a menu.
+// Lines may end with CR, LF or both.
+func terminated(b []byte) bool {
+	for {
+		i := bytes.IndexAny(b, "\r\n")
+		if i < 0 {
+			return false
+		}
+		if i == 1 && b[0] == '.' {
+			return true
+		}
+		b = b[i+1:]
+	}
+}
+
+// ItemAction returns a grawler.ItemActionFunc that fetches every item with one
+// of the ItemTypes in types using grawler.ResourceOpener o and stores it in the
+// Mirror. Every item is fetched at most once per run. Errors are reported
+// using OnError and do not stop the crawler. o should open resources the way
+// the crawled menus are opened, e.g. through the same circuit breaker, but not
+// through the Opener of the Mirror.
+func (m *Mirror) ItemAction(o grawler.ResourceOpener, types string) grawler.ItemActionFunc {
+	return func(i grawler.Item) error {
+		if i.Type == grawler.DirectoryType || !strings.ContainsRune(types, rune(i.Type)) {
+			return nil
+		}
+
+		m.mtx.Lock()
+		u := i.String()
+		fetched := m.fetched[u]
+		m.fetched[u] = true
+		m.mtx.Unlock()
+		if fetched {
+			return nil
+		}
+
+		err := m.fetch(o, &i.Resource)
+		if err != nil {
+			m.error(fmt.Errorf("Could not mirror %v: %v", u, err))
+		}
+		return nil
+	}
+}
+
+func (m *Mirror) fetch(o grawler.ResourceOpener, r *grawler.Resource) error {
+	rc, err := o(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var in io.Reader = rc
+	if m.MaxBytes > 0 {
+		in = io.LimitReader(rc, m.MaxBytes+1)
+	}
+	b, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if m.MaxBytes > 0 && int64(len(b)) > m.MaxBytes {
+		return fmt.Errorf("Larger than %d bytes", m.MaxBytes)
+	}
+
+	_, err = m.Store(r, b)
+	return err
+}
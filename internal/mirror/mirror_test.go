@@ -0,0 +1,292 @@
+package mirror
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+var pathTests = []struct {
+	resource *grawler.Resource
+	expected string
+}{
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""},
+		"localhost_70/.gophermap"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "Gopher.Floodgap.com", Port: "70"}, Type: '1', Selector: "/"},
+		"gopher.floodgap.com_70/.gophermap"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: "/dir/sub"},
+		"localhost_70/dir/sub/.gophermap"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/dir/file.txt"},
+		"localhost_70/dir/file.txt/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: ""},
+		"localhost_70/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "::1", Port: "70"}, Type: '0', Selector: "a"},
+		"__1_363baea9_70/a/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/../../etc/passwd"},
+		"localhost_70/_.._9d891e73/_.._9d891e73/etc/passwd/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/a file?x=1"},
+		"localhost_70/a_file_x=1_753fd26b/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/.gophermap"},
+		"localhost_70/_.gophermap_db7e5ee3/.0"},
+	// Resources of different types and resources below them do not
+	// collide.
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/foo"},
+		"localhost_70/foo/.0"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '9', Selector: "/foo"},
+		"localhost_70/foo/.9"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: "/foo/bar"},
+		"localhost_70/foo/bar/.gophermap"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '+', Selector: "/foo"},
+		"localhost_70/foo/.2b"},
+	{&grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '0', Selector: "/" + strings.Repeat("x", 200)},
+		"localhost_70/" + strings.Repeat("x", 119) + "_94218caa/.0"},
+}
+
+func TestPath(t *testing.T) {
+	for _, tt := range pathTests {
+		p := Path(tt.resource)
+		if p != filepath.FromSlash(tt.expected) {
+			t.Errorf("%q != %q", p, tt.expected)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	root := t.TempDir()
+	m, err := Open(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	r := pathTests[2].resource
+	changed, err := m.Store(r, []byte("iHello\t\terror.host\t1\r\n.\r\n"))
+	if err != nil || !changed {
+		t.Fatalf("Unexpected result storing %v: %v, %v", r, changed, err)
+	}
+
+	now = now.Add(time.Hour)
+	changed, err = m.Store(r, []byte("iHello\t\terror.host\t1\r\n.\r\n"))
+	if err != nil || changed {
+		t.Fatalf("Unexpected result storing unchanged %v: %v, %v", r, changed, err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	manifest, err := ReadManifest(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	e := manifest[Path(r)]
+	if e == nil {
+		t.Fatalf("Entry not found in manifest: %v", manifest)
+	}
+	if e.URL != r.String() || e.Type != "1" || e.Size != 25 {
+		t.Errorf("Unexpected manifest entry: %#v", e)
+	}
+	if !e.Fetched.Equal(now) || !e.Modified.Equal(now.Add(-time.Hour)) {
+		t.Errorf("Unexpected timestamps: %#v", e)
+	}
+
+	// A new run on the same directory does not rewrite the file either.
+	m, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	changed, err = m.Store(r, []byte("iHello\t\terror.host\t1\r\n.\r\n"))
+	if err != nil || changed {
+		t.Fatalf("Unexpected result storing unchanged %v after reopening: %v, %v", r, changed, err)
+	}
+	changed, err = m.Store(r, []byte("iChanged\t\terror.host\t1\r\n.\r\n"))
+	if err != nil || !changed {
+		t.Fatalf("Unexpected result storing changed %v: %v, %v", r, changed, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(root, Path(r)))
+	if err != nil || !bytes.HasPrefix(b, []byte("iChanged")) {
+		t.Errorf("Unexpected file content: %q, %v", b, err)
+	}
+}
+
+type stringReadCloser struct {
+	*strings.Reader
+}
+
+func (stringReadCloser) Close() error {
+	return nil
+}
+
+var mirrorContent = map[string]string{
+	"gopher://localhost:70/1":          "0Text\t/file.txt\tlocalhost\t70\r\n9Binary\t/file.bin\tlocalhost\t70\r\n1Dir\t/dir\tlocalhost\t70\r\n.\r\n",
+	"gopher://localhost:70/0/file.txt": "Hello gopherspace\r\n.\r\n",
+	"gopher://localhost:70/9/file.bin": "\x00\x01\x02",
+}
+
+func mockOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	s, ok := mirrorContent[r.String()]
+	if !ok {
+		return nil, fmt.Errorf("Not found: %v", r)
+	}
+	return stringReadCloser{strings.NewReader(s)}, nil
+}
+
+func TestOpenerAndItemAction(t *testing.T) {
+	root := t.TempDir()
+	m, err := Open(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var errs []error
+	m.OnError = func(err error) { errs = append(errs, err) }
+
+	findings := make(chan *grawler.CrawlFinding)
+	go func() {
+		for range findings {
+		}
+	}()
+	defer close(findings)
+
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""}
+	ia := m.ItemAction(mockOpener, "0")
+	err = grawler.ResourceCrawler(m.Opener(mockOpener), r, findings, ia, ia)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(errs) != 0 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	expected := map[string]string{
+		"localhost_70/.gophermap":  mirrorContent["gopher://localhost:70/1"],
+		"localhost_70/file.txt/.0": mirrorContent["gopher://localhost:70/0/file.txt"],
+	}
+	manifest, err := ReadManifest(root)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest) != len(expected) {
+		t.Errorf("Unexpected manifest: %v", manifest)
+	}
+	for p, c := range expected {
+		b, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(p)))
+		if err != nil || string(b) != c {
+			t.Errorf("Unexpected content of %q: %q, %v", p, b, err)
+		}
+	}
+}
+
+func TestManifestChangedMenus(t *testing.T) {
+	old := Manifest{
+		"a_70/.gophermap":     {URL: "gopher://a:70/1", Type: "1", SHA256: "1"},
+		"a_70/sub/.gophermap": {URL: "gopher://a:70/1/sub", Type: "1", SHA256: "2"},
+		"a_70/file/.0":        {URL: "gopher://a:70/0/file", Type: "0", SHA256: "3"},
+		"b_70/.gophermap":     {URL: "gopher://b:70/1", Type: "1", SHA256: "4"},
+	}
+	m := Manifest{
+		"a_70/.gophermap":     {URL: "gopher://a:70/1", Type: "1", SHA256: "1"},
+		"a_70/sub/.gophermap": {URL: "gopher://a:70/1/sub", Type: "1", SHA256: "changed"},
+		"a_70/file/.0":        {URL: "gopher://a:70/0/file", Type: "0", SHA256: "changed"},
+		"c_70/.gophermap":     {URL: "gopher://c:70/1", Type: "1", SHA256: "5"},
+	}
+
+	changed := m.ChangedMenus(old)
+	if len(changed) != 1 || changed[0] != "gopher://a:70/1/sub" {
+		t.Errorf("Unexpected changed menus: %v", changed)
+	}
+}
+
+// errAfterReader reads s and fails with err afterwards.
+type errAfterReader struct {
+	*strings.Reader
+	err error
+}
+
+func (r errAfterReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		err = r.err
+	}
+	return n, err
+}
+
+func (errAfterReader) Close() error {
+	return nil
+}
+
+var partialTests = []struct {
+	content string
+	err     error
+	limits  grawler.Limits
+	stored  bool
+}{
+	{mirrorContent["gopher://localhost:70/1"], nil, grawler.Limits{}, true},
+	// The whole menu has been read, although only one item is processed.
+	{mirrorContent["gopher://localhost:70/1"], nil, grawler.Limits{MaxItems: 1}, true},
+	{mirrorContent["gopher://localhost:70/1"], nil, grawler.Limits{MaxBytes: 20}, false},
+	{"0Text\t/file.txt\tlocalhost\t70\r\n", io.ErrUnexpectedEOF, grawler.Limits{}, false},
+	// The menu is complete, the error after the terminator is not read.
+	{"0Text\t/file.txt\tlocalhost\t70\r\n.\r\n", io.ErrUnexpectedEOF, grawler.Limits{}, true},
+	{"0Text\t/file.txt\tlocalhost\t70\r.\r", nil, grawler.Limits{}, true},
+}
+
+func TestOpenerPartialMenus(t *testing.T) {
+	findings := make(chan *grawler.CrawlFinding)
+	go func() {
+		for range findings {
+		}
+	}()
+	defer close(findings)
+
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""}
+	for _, tt := range partialTests {
+		m, err := Open(t.TempDir())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		o := m.Opener(func(r *grawler.Resource) (io.ReadCloser, error) {
+			return errAfterReader{strings.NewReader(tt.content), tt.err}, nil
+		})
+		grawler.CrawlWithLimits(o, r, tt.limits, findings)
+
+		if _, stored := m.manifest[Path(r)]; stored != tt.stored {
+			t.Errorf("%q, %v, %+v: %v != %v", tt.content, tt.err, tt.limits, stored, tt.stored)
+		}
+	}
+}
+
+func TestItemActionMaxBytes(t *testing.T) {
+	m, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var errs []error
+	m.OnError = func(err error) { errs = append(errs, err) }
+	m.MaxBytes = 10
+
+	i, err := grawler.NewItemFromGopherLine("0Text\t/file.txt\tlocalhost\t70")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	m.ItemAction(mockOpener, "0")(*i)
+
+	if len(errs) != 1 {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+	if len(m.manifest) != 0 {
+		t.Errorf("Unexpected manifest: %v", m.manifest)
+	}
+}
@@ -0,0 +1,257 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Liveness monitoring of known gopher servers.
+package monitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/blabber/grawler/gopher"
+	"github.com/blabber/grawler/grawler"
+)
+
+// maxProbeSize is the maximum number of bytes read from a root menu when
+// probing a gopher server.
+const maxProbeSize = 1 << 20
+
+// HostStatus is the uptime history of a gopher server.
+type HostStatus struct {
+	FirstSeen           time.Time // first time the server has been probed
+	LastAlive           time.Time // last time the server has been found alive
+	LastChecked         time.Time // last time the server has been probed
+	LastError           string    // the error of the last failed probe
+	ConsecutiveFailures int
+	Checks              int
+	Successes           int
+}
+
+// Alive returns true, if the last probe of the server succeeded.
+func (s *HostStatus) Alive() bool {
+	return s.Checks > 0 && s.ConsecutiveFailures == 0
+}
+
+// Uptime returns the ratio of successful probes.
+func (s *HostStatus) Uptime() float64 {
+	if s.Checks == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(s.Checks)
+}
+
+// Store maps the Host strings of monitored gopher servers to their HostStatus.
+type Store map[string]*HostStatus
+
+// LoadStore reads a Store from the file name. A missing file yields an empty
+// Store.
+func LoadStore(name string) (Store, error) {
+	s := make(Store)
+
+	b, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &s)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read monitor store %s: %v", name, err)
+	}
+	return s, nil
+}
+
+// Save writes the Store to the file name. The file is replaced atomically.
+func (s Store) Save(name string) error {
+	b, err := json.MarshalIndent(s, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
+// Hosts returns the Hosts of all servers in the Store in lexical order.
+func (s Store) Hosts() []*grawler.Host {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var hosts []*grawler.Host
+	for _, k := range keys {
+		if h, err := grawler.ParseHost(k); err == nil {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Record records the result of probing *grawler.Host h at time now. A nil err
+// denotes a successful probe.
+func (s Store) Record(h *grawler.Host, now time.Time, err error) {
+	st, ok := s[h.String()]
+	if !ok {
+		st = &HostStatus{FirstSeen: now}
+		s[h.String()] = st
+	}
+
+	st.Checks++
+	st.LastChecked = now
+	if err != nil {
+		st.ConsecutiveFailures++
+		st.LastError = err.Error()
+		return
+	}
+	st.Successes++
+	st.ConsecutiveFailures = 0
+	st.LastAlive = now
+	st.LastError = ""
+}
+
+// WriteReport writes a status report of all servers in the Store to w. Servers
+// are listed alphabetically.
+func (s Store) WriteReport(w io.Writer) error {
+	alive := 0
+	for _, st := range s {
+		if st.Alive() {
+			alive++
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Gopherholes\n  alive:\t%d\n  dead:\t%d\n  total:\t%d\n\n",
+		alive, len(s)-alive, len(s))
+	fmt.Fprintln(tw, "HOST\tSTATUS\tUPTIME\tLAST ALIVE\tFAILURES\tLAST ERROR")
+	for _, h := range s.Hosts() {
+		st := s[h.String()]
+
+		status := "dead"
+		if st.Alive() {
+			status = "alive"
+		}
+		last := "never"
+		if !st.LastAlive.IsZero() {
+			last = st.LastAlive.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%s\t%d\t%s\n", h, status, st.Uptime()*100,
+			last, st.ConsecutiveFailures, st.LastError)
+	}
+	return tw.Flush()
+}
+
+// Probe checks whether the gopher server *grawler.Host h is alive by reading
+// its root menu using grawler.ResourceOpener o. The server is alive if the
+// menu can be read without errors. Menus larger than maxProbeSize are not read
+// completely and yield a *grawler.TruncatedError.
+func Probe(o grawler.ResourceOpener, h *grawler.Host) error {
+	r := &grawler.Resource{Host: h, Type: grawler.DirectoryType, Selector: ""}
+	rc, err := o(r)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	in := &io.LimitedReader{R: rc, N: maxProbeSize + 1}
+	// Lines are only limited by the size of the menu.
+	scan := gopher.NewMenuScanner(in, maxProbeSize+1)
+	lines := 0
+	for scan.Scan() {
+		lines++
+	}
+	if err := scan.Err(); err != nil {
+		return err
+	}
+	if in.N == 0 {
+		return &grawler.TruncatedError{Resource: r, Limit: grawler.LimitBytes, Lines: lines}
+	}
+	return nil
+}
+
+// Monitor probes gopher servers and records the results in a Store.
+type Monitor struct {
+	Opener  grawler.ResourceOpener
+	Workers int // number of concurrent probes, at least one is used
+
+	now func() time.Time
+}
+
+// New creates a new Monitor probing servers using grawler.ResourceOpener o with
+// the given number of concurrent workers.
+func New(o grawler.ResourceOpener, workers int) *Monitor {
+	return &Monitor{Opener: o, Workers: workers, now: time.Now}
+}
+
+// Round probes all hosts and records the results in Store s. It returns the
+// number of servers found alive.
+func (m *Monitor) Round(s Store, hosts []*grawler.Host) int {
+	type result struct {
+		host *grawler.Host
+		at   time.Time
+		err  error
+	}
+
+	jobs := make(chan *grawler.Host)
+	results := make(chan result)
+
+	workers := m.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for h := range jobs {
+				err := Probe(m.Opener, h)
+				results <- result{h, m.now(), err}
+			}
+		}()
+	}
+	go func() {
+		for _, h := range hosts {
+			jobs <- h
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	alive := 0
+	for r := range results {
+		s.Record(r.host, r.at, r.err)
+		if r.err == nil {
+			alive++
+		}
+	}
+	return alive
+}
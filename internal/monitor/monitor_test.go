@@ -0,0 +1,147 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+type stringReadCloser struct {
+	*strings.Reader
+}
+
+func (stringReadCloser) Close() error {
+	return nil
+}
+
+// down lists the hosts that are currently down for mockOpener.
+var down = make(map[string]bool)
+
+func mockOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	if down[r.Host.String()] {
+		return nil, fmt.Errorf("connection refused")
+	}
+	return stringReadCloser{strings.NewReader("iHello\t\terror.host\t1\r\n.\r\n")}, nil
+}
+
+func TestMonitorRound(t *testing.T) {
+	hosts := []*grawler.Host{
+		{Hostname: "alive", Port: "70"},
+		{Hostname: "flaky", Port: "70"},
+		{Hostname: "dead", Port: "70"},
+	}
+
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := New(mockOpener, 2)
+	m.now = func() time.Time { return now }
+	s := make(Store)
+
+	down = map[string]bool{"dead:70": true}
+	if n := m.Round(s, hosts); n != 2 {
+		t.Errorf("Unexpected number of alive hosts: %d != 2", n)
+	}
+
+	now = now.Add(time.Hour)
+	down = map[string]bool{"dead:70": true, "flaky:70": true}
+	if n := m.Round(s, hosts); n != 1 {
+		t.Errorf("Unexpected number of alive hosts: %d != 1", n)
+	}
+
+	expected := map[string]struct {
+		alive     bool
+		lastAlive time.Time
+		failures  int
+		uptime    float64
+	}{
+		"alive:70": {true, now, 0, 1},
+		"flaky:70": {false, now.Add(-time.Hour), 1, 0.5},
+		"dead:70":  {false, time.Time{}, 2, 0},
+	}
+	for h, e := range expected {
+		st := s[h]
+		if st == nil {
+			t.Errorf("Host %q not found in store", h)
+			continue
+		}
+		if st.Alive() != e.alive || !st.LastAlive.Equal(e.lastAlive) ||
+			st.ConsecutiveFailures != e.failures || st.Uptime() != e.uptime {
+			t.Errorf("Unexpected status of %q: %#v", h, st)
+		}
+		if !st.FirstSeen.Equal(now.Add(-time.Hour)) || !st.LastChecked.Equal(now) {
+			t.Errorf("Unexpected timestamps of %q: %#v", h, st)
+		}
+	}
+	if s["dead:70"].LastError != "connection refused" {
+		t.Errorf("Unexpected last error: %q", s["dead:70"].LastError)
+	}
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "monitor.json")
+
+	s, err := LoadStore(name)
+	if err != nil || len(s) != 0 {
+		t.Fatalf("Unexpected result loading missing store: %v, %v", s, err)
+	}
+
+	now := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.Record(&grawler.Host{Hostname: "a", Port: "70"}, now, nil)
+	s.Record(&grawler.Host{Hostname: "b", Port: "70"}, now, fmt.Errorf("timeout"))
+	if err := s.Save(name); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	l, err := LoadStore(name)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(l) != 2 || !l["a:70"].Alive() || l["b:70"].Alive() || !l["a:70"].LastAlive.Equal(now) {
+		t.Errorf("Unexpected store after loading: %#v", l)
+	}
+
+	var b bytes.Buffer
+	if err := l.WriteReport(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, e := range []string{"alive:  1", "dead:   1", "a:70  alive   100.0%  2016-01-01T00:00:00Z  0",
+		"b:70  dead    0.0%    never                 1         timeout"} {
+		if !strings.Contains(b.String(), e) {
+			t.Errorf("%q not found in report:\n%s", e, b.String())
+		}
+	}
+}
+
+var probeTests = []struct {
+	menu      string
+	truncated bool
+}{
+	{"iHello\t\terror.host\t1\r\n.\r\n", false},
+	{"iHello\t\terror.host\t1\r\n", false},
+	{"i" + strings.Repeat("x", 100000) + "\t\terror.host\t1\r\n.\r\n", false},
+	{strings.Repeat("iHello\t\terror.host\t1\r\n", maxProbeSize/20), true},
+	{strings.Repeat("x", maxProbeSize+1), true},
+}
+
+func TestProbe(t *testing.T) {
+	for _, tt := range probeTests {
+		o := func(*grawler.Resource) (io.ReadCloser, error) {
+			return stringReadCloser{strings.NewReader(tt.menu)}, nil
+		}
+		err := Probe(o, &grawler.Host{Hostname: "a", Port: "70"})
+
+		var te *grawler.TruncatedError
+		if truncated := errors.As(err, &te); truncated != tt.truncated {
+			t.Errorf("%.20q: Unexpected error: %v", tt.menu, err)
+		}
+		if !tt.truncated && err != nil {
+			t.Errorf("%.20q: Unexpected error: %v", tt.menu, err)
+		}
+	}
+}
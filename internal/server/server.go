@@ -0,0 +1,229 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// A gopher server serving the results of a crawl.
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/index"
+)
+
+// Selectors served by Server.
+const (
+	RootSelector    = ""
+	SearchSelector  = "/search"
+	HostsSelector   = "/hosts"
+	RankingSelector = "/ranking"
+	DeadSelector    = "/dead"
+)
+
+// maxRequestLength is the maximum length of a request line, including its line
+// ending. Longer requests are answered with an error.
+const maxRequestLength = 4096
+
+// Server is a gopher server answering type 7 search queries using Index and
+// serving menus describing the gopher servers in Graph. Hostname and Port are
+// used to reference the server itself in its menus.
+type Server struct {
+	Index    *index.Index
+	Graph    *grawler.Graph
+	Hostname string
+	Port     string
+
+	// MaxResults limits the number of items returned for a search query.
+	// Zero means no limit.
+	MaxResults int
+}
+
+// Serve accepts connections on net.Listener l and serves each connection in
+// its own goroutine. Serve returns when l.Accept fails, e.g. because l has
+// been closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn reads a single request from conn, writes the response and closes
+// conn. A connection times out after one minute.
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+
+	err := conn.SetDeadline(time.Now().Add(time.Minute))
+	if err != nil {
+		return
+	}
+
+	r := bufio.NewReaderSize(io.LimitReader(conn, maxRequestLength), maxRequestLength)
+	line, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return
+	}
+
+	w := bufio.NewWriter(conn)
+	if !strings.HasSuffix(line, "\n") && len(line) >= maxRequestLength {
+		m := &menu{w}
+		m.error(fmt.Sprintf("Request longer than %d bytes", maxRequestLength))
+		m.end()
+	} else {
+		s.Handle(w, strings.TrimRight(line, "\r\n"))
+	}
+	w.Flush()
+}
+
+// Handle writes the response to request to w. The request is a selector string,
+// optionally followed by a tab and a search query.
+func (s *Server) Handle(w io.Writer, request string) {
+	selector, query := request, ""
+	if i := strings.IndexByte(request, '\t'); i >= 0 {
+		selector, query = request[:i], request[i+1:]
+	}
+
+	m := &menu{w}
+	switch selector {
+	case RootSelector, "/":
+		s.root(m)
+	case SearchSelector:
+		s.search(m, query)
+	case HostsSelector:
+		s.hosts(m)
+	case RankingSelector:
+		s.ranking(m)
+	case DeadSelector:
+		s.dead(m)
+	default:
+		m.error(fmt.Sprintf("Unknown selector: %s", selector))
+	}
+	m.end()
+}
+
+func (s *Server) root(m *menu) {
+	m.info("grawler - the gopherspace as seen by the last crawl")
+	m.info("")
+	if s.Index != nil {
+		m.item('7', fmt.Sprintf("Search %d crawled items", s.Index.Len()), SearchSelector, s.Hostname, s.Port)
+	}
+	if s.Graph != nil {
+		m.item('1', "Responsive gopher servers", HostsSelector, s.Hostname, s.Port)
+		m.item('1', "Gopher servers ranked by references", RankingSelector, s.Hostname, s.Port)
+		m.item('1', "Unresponsive gopher servers", DeadSelector, s.Hostname, s.Port)
+	}
+}
+
+func (s *Server) search(m *menu, query string) {
+	if s.Index == nil {
+		m.error("No index available")
+		return
+	}
+	if strings.TrimSpace(query) == "" {
+		m.error("Empty search query")
+		return
+	}
+
+	docs := s.Index.Search(query)
+	if len(docs) == 0 {
+		m.info(fmt.Sprintf("No items found for %q", query))
+		return
+	}
+
+	m.info(fmt.Sprintf("%d items found for %q", len(docs), query))
+	m.info("")
+	for i, d := range docs {
+		if s.MaxResults > 0 && i >= s.MaxResults {
+			m.info(fmt.Sprintf("... %d more items", len(docs)-s.MaxResults))
+			break
+		}
+		m.item(d.Type, d.Display, d.Selector, d.Hostname, d.Port)
+	}
+}
+
+func (s *Server) hosts(m *menu) {
+	if s.Graph == nil {
+		m.error("No graph available")
+		return
+	}
+
+	for _, h := range s.Graph.Hosts() {
+		if s.Graph.Alive(h.String()) {
+			m.host(h, h.String())
+		}
+	}
+}
+
+func (s *Server) ranking(m *menu) {
+	if s.Graph == nil {
+		m.error("No graph available")
+		return
+	}
+
+	d := s.Graph.InDegrees()
+	hosts := s.Graph.Hosts()
+	sort.SliceStable(hosts, func(i, j int) bool {
+		return d[hosts[i].String()] > d[hosts[j].String()]
+	})
+
+	for _, h := range hosts {
+		n := h.String()
+		status := ""
+		if !s.Graph.Alive(n) {
+			status = ", unresponsive"
+		}
+		m.host(h, fmt.Sprintf("%s (%d references%s)", n, d[n], status))
+	}
+}
+
+func (s *Server) dead(m *menu) {
+	if s.Graph == nil {
+		m.error("No graph available")
+		return
+	}
+
+	for _, h := range s.Graph.Hosts() {
+		if !s.Graph.Alive(h.String()) {
+			m.host(h, h.String())
+		}
+	}
+}
+
+// menu writes gopher menu lines. Write errors are ignored, as there is no way
+// to report them to the client anyway.
+type menu struct {
+	w io.Writer
+}
+
+func (m *menu) item(t grawler.ItemType, display, selector, hostname, port string) {
+	display = strings.NewReplacer("\t", " ", "\r", "", "\n", " ").Replace(display)
+	fmt.Fprintf(m.w, "%v%s\t%s\t%s\t%s\r\n", t, display, selector, hostname, port)
+}
+
+func (m *menu) host(h *grawler.Host, display string) {
+	m.item(grawler.DirectoryType, display, "", h.Hostname, h.Port)
+}
+
+func (m *menu) info(display string) {
+	m.item(grawler.InformationalMessageType, display, "", "error.host", "1")
+}
+
+func (m *menu) error(display string) {
+	m.item(grawler.ErrorMessageType, display, "", "error.host", "1")
+}
+
+func (m *menu) end() {
+	io.WriteString(m.w, ".\r\n")
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/index"
+)
+
+var testDotfile = `strict digraph {
+	"gopher.floodgap.com:70"[alive=true]
+	"gopher.floodgap.com:70" -> "gopher.floodgap.com:70"
+	"gopher.floodgap.com:70" -> "sdf.org:70"
+	"gopher.floodgap.com:70" -> "dead.example.com:70"
+	"sdf.org:70"[alive=true]
+	"sdf.org:70" -> "gopher.floodgap.com:70"
+	"sdf.org:70" -> "dead.example.com:70"
+}
+`
+
+var testItems = []string{
+	"0About Veronica-2\t/v2/help/about.txt\tgopher.floodgap.com\t70",
+	"7Search Veronica-2\t/v2/vs\tgopher.floodgap.com\t70",
+	"1SDF Public Access UNIX System\t/\tsdf.org\t70",
+}
+
+// startServer starts a Server on a loopback address. It returns the Host of
+// the Server and a function to stop it.
+func startServer(t *testing.T) (*grawler.Host, func()) {
+	g, err := grawler.ReadGraph(strings.NewReader(testDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	idx := index.New()
+	for _, l := range testItems {
+		i, err := grawler.NewItemFromGopherLine(l)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		idx.Add(*i)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %v", err)
+	}
+	h, err := grawler.ParseHost(l.Addr().String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	s := &Server{Index: idx, Graph: g, Hostname: h.Hostname, Port: h.Port}
+	go s.Serve(l)
+
+	return h, func() { l.Close() }
+}
+
+// crawl crawls the menu identified by selector on Host h using
+// grawler.ResourceCrawler. It returns the URIs of all items found.
+func crawl(t *testing.T, h *grawler.Host, selector string) []string {
+	findings := make(chan *grawler.CrawlFinding)
+	go func() {
+		for range findings {
+		}
+	}()
+	defer close(findings)
+
+	var items []string
+	ia := func(i grawler.Item) error {
+		items = append(items, i.String())
+		return nil
+	}
+
+	r := &grawler.Resource{Host: h, Type: grawler.DirectoryType, Selector: selector}
+	err := grawler.ResourceCrawler(grawler.NetResourceOpener, r, findings, ia)
+	if err != nil {
+		t.Fatalf("Unexpected error crawling %q: %v", selector, err)
+	}
+	return items
+}
+
+func TestServer(t *testing.T) {
+	h, stop := startServer(t)
+	defer stop()
+
+	self := fmt.Sprintf("gopher://%v/", h)
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{RootSelector, []string{
+			self + "7/search",
+			self + "1/hosts",
+			self + "1/ranking",
+			self + "1/dead",
+		}},
+		{SearchSelector + "\tveronica", []string{
+			"gopher://gopher.floodgap.com:70/0/v2/help/about.txt",
+			"gopher://gopher.floodgap.com:70/7/v2/vs",
+		}},
+		{SearchSelector + "\tnothing", nil},
+		{HostsSelector, []string{
+			"gopher://gopher.floodgap.com:70/1",
+			"gopher://sdf.org:70/1",
+		}},
+		{RankingSelector, []string{
+			"gopher://dead.example.com:70/1",
+			"gopher://gopher.floodgap.com:70/1",
+			"gopher://sdf.org:70/1",
+		}},
+		{DeadSelector, []string{
+			"gopher://dead.example.com:70/1",
+		}},
+		{"/unknown", nil},
+	}
+
+	for _, tt := range tests {
+		items := crawl(t, h, tt.selector)
+		if strings.Join(items, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("Selector %q: %q != %q", tt.selector, items, tt.expected)
+		}
+	}
+}
+
+func TestHandleRanking(t *testing.T) {
+	g, err := grawler.ReadGraph(strings.NewReader(testDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	s := &Server{Graph: g, Hostname: "localhost", Port: "70"}
+
+	var b bytes.Buffer
+	s.Handle(&b, RankingSelector)
+
+	expected := "1dead.example.com:70 (2 references, unresponsive)\t\tdead.example.com\t70\r\n" +
+		"1gopher.floodgap.com:70 (1 references)\t\tgopher.floodgap.com\t70\r\n" +
+		"1sdf.org:70 (1 references)\t\tsdf.org\t70\r\n" +
+		".\r\n"
+	if b.String() != expected {
+		t.Fatalf("Unexpected response: %q != %q", b.String(), expected)
+	}
+}
+
+func TestHandleSearchMaxResults(t *testing.T) {
+	idx := index.New()
+	for _, l := range testItems {
+		i, _ := grawler.NewItemFromGopherLine(l)
+		idx.Add(*i)
+	}
+	s := &Server{Index: idx, Hostname: "localhost", Port: "70", MaxResults: 1}
+
+	var b bytes.Buffer
+	s.Handle(&b, SearchSelector+"\tveronica")
+
+	if !strings.Contains(b.String(), "... 1 more items") {
+		t.Errorf("Search results not limited: %q", b.String())
+	}
+	if strings.Contains(b.String(), "/v2/vs") {
+		t.Errorf("Search results not limited: %q", b.String())
+	}
+}
+
+func TestServeConnLongRequest(t *testing.T) {
+	s := &Server{Index: index.New(), Graph: grawler.NewGraph()}
+	for _, tt := range []struct {
+		request string
+		err     bool
+	}{
+		{DeadSelector + strings.Repeat("x", maxRequestLength-len(DeadSelector)-2) + "\r\n", false},
+		{DeadSelector + strings.Repeat("x", maxRequestLength) + "\r\n", true},
+	} {
+		client, server := net.Pipe()
+		go s.ServeConn(server)
+		go io.WriteString(client, tt.request)
+
+		b, _ := io.ReadAll(client)
+		client.Close()
+		if err := strings.Contains(string(b), "Request longer than"); err != tt.err {
+			t.Errorf("%.20q: Unexpected response: %q", tt.request, b)
+		}
+		if unknown := strings.Contains(string(b), "Unknown selector"); unknown == tt.err {
+			t.Errorf("%.20q: Unexpected response: %q", tt.request, b)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Package stats computes statistics of crawl results.
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/itemlog"
+)
+
+// Count is the number of occurrences of something identified by Name.
+type Count struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Addresses is the number of gopherholes by the kind of address they are known
+// by.
+type Addresses struct {
+	Hostname int `json:"hostname"`
+	IPv4     int `json:"ipv4"`
+	IPv6     int `json:"ipv6"`
+}
+
+// Stats are the statistics of a crawl. Lists of Counts are sorted by
+// descending count and name, lists named Top are limited to the configured
+// number of entries.
+type Stats struct {
+	Alive int `json:"alive"`
+	Dead  int `json:"dead"`
+	Total int `json:"total"`
+
+	TopAliveTLDs []Count   `json:"top_alive_tlds"`
+	TopDeadTLDs  []Count   `json:"top_dead_tlds"`
+	Ports        []Count   `json:"ports"`
+	Addresses    Addresses `json:"addresses"`
+	TopInDegree  []Count   `json:"top_in_degree"`
+	TopOutDegree []Count   `json:"top_out_degree"`
+
+	// The following statistics are only available if an item log has
+	// been added.
+	Items               int     `json:"items,omitempty"`
+	ItemTypes           []Count `json:"item_types,omitempty"`
+	TopResourcesPerHost []Count `json:"top_resources_per_host,omitempty"`
+	TopLargestMenus     []Count `json:"top_largest_menus,omitempty"`
+
+	top int
+}
+
+// New computes the statistics of the crawl described by grawler.Graph g. Lists
+// named Top are limited to top entries, 0 means no limit.
+//
+// The TLD of a gopherhole is the last label of its hostname. Gopherholes known
+// by their IP address are not counted in the TLD lists.
+func New(g *grawler.Graph, top int) *Stats {
+	s := &Stats{top: top}
+
+	alive := make(map[string]int)
+	dead := make(map[string]int)
+	ports := make(map[string]int)
+	for _, n := range g.SortedNodes() {
+		s.Total++
+		tlds := dead
+		if g.Alive(n) {
+			s.Alive++
+			tlds = alive
+		} else {
+			s.Dead++
+		}
+
+		h, err := grawler.ParseHost(n)
+		if err != nil {
+			continue
+		}
+		ports[h.Port]++
+
+		ip := net.ParseIP(h.Hostname)
+		switch {
+		case ip == nil:
+			s.Addresses.Hostname++
+			tlds[tld(h.Hostname)]++
+		case ip.To4() != nil:
+			s.Addresses.IPv4++
+		default:
+			s.Addresses.IPv6++
+		}
+	}
+
+	s.TopAliveTLDs = s.sorted(alive, true)
+	s.TopDeadTLDs = s.sorted(dead, true)
+	s.Ports = s.sorted(ports, false)
+	s.TopInDegree = s.sorted(nonZero(g.InDegrees()), true)
+	s.TopOutDegree = s.sorted(nonZero(g.OutDegrees()), true)
+
+	return s
+}
+
+// tld returns the top level domain of hostname.
+func tld(hostname string) string {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+	return hostname[strings.LastIndex(hostname, ".")+1:]
+}
+
+// nonZero returns the entries of m with a count greater than zero.
+func nonZero(m map[string]int) map[string]int {
+	r := make(map[string]int, len(m))
+	for k, v := range m {
+		if v > 0 {
+			r[k] = v
+		}
+	}
+	return r
+}
+
+// sorted returns the entries of m as Counts sorted by descending count and
+// name. The list is limited to the configured number of entries, if limit is
+// true.
+func (s *Stats) sorted(m map[string]int, limit bool) []Count {
+	c := make([]Count, 0, len(m))
+	for k, v := range m {
+		c = append(c, Count{k, v})
+	}
+	sort.Slice(c, func(i, j int) bool {
+		if c[i].Count != c[j].Count {
+			return c[i].Count > c[j].Count
+		}
+		return c[i].Name < c[j].Name
+	})
+	if limit && s.top > 0 && len(c) > s.top {
+		c = c[:s.top]
+	}
+	return c
+}
+
+// AddItems adds the statistics of the items logged in records. Informational
+// messages are counted as items, but not as resources. Menus are identified by
+// the parent URLs of the records, so item logs in the url format do not yield
+// menu sizes.
+func (s *Stats) AddItems(records []itemlog.Record) {
+	types := make(map[string]int)
+	resources := make(map[string]map[string]bool)
+	menus := make(map[string]int)
+	for _, r := range records {
+		s.Items++
+		types[r.TypeName]++
+
+		if r.Parent != "" {
+			menus[r.Parent]++
+		}
+
+		if r.Type == grawler.InformationalMessageType.String() {
+			continue
+		}
+		h := (&grawler.Host{Hostname: r.Hostname, Port: r.Port}).String()
+		if resources[h] == nil {
+			resources[h] = make(map[string]bool)
+		}
+		resources[h][r.URL] = true
+	}
+
+	perHost := make(map[string]int, len(resources))
+	for h, urls := range resources {
+		perHost[h] = len(urls)
+	}
+
+	s.ItemTypes = s.sorted(types, false)
+	s.TopResourcesPerHost = s.sorted(perHost, true)
+	s.TopLargestMenus = s.sorted(menus, true)
+}
+
+// WriteText writes a human readable report to w.
+func (s *Stats) WriteText(w io.Writer) error {
+	top := "All"
+	if s.top > 0 {
+		top = fmt.Sprintf("Top %d", s.top)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Gopherholes\n")
+	fmt.Fprintf(&b, "  alive:  %d\n", s.Alive)
+	fmt.Fprintf(&b, "  dead:   %d\n", s.Dead)
+	fmt.Fprintf(&b, "  total:  %d\n", s.Total)
+
+	writeTLDs := func(title string, c []Count) {
+		fmt.Fprintf(&b, "\n%s\n", title)
+		for _, t := range c {
+			fmt.Fprintf(&b, "  .%-5s %d\n", t.Name, t.Count)
+		}
+	}
+	writeTLDs(top+" TLDs for alive gopherholes", s.TopAliveTLDs)
+	writeTLDs(top+" TLDs for dead gopherholes", s.TopDeadTLDs)
+
+	fmt.Fprintf(&b, "\nAddresses\n")
+	fmt.Fprintf(&b, "  hostname:  %d\n", s.Addresses.Hostname)
+	fmt.Fprintf(&b, "  ipv4:      %d\n", s.Addresses.IPv4)
+	fmt.Fprintf(&b, "  ipv6:      %d\n", s.Addresses.IPv6)
+
+	writeCounts(&b, "Ports", s.Ports)
+	writeCounts(&b, top+" most referenced gopherholes", s.TopInDegree)
+	writeCounts(&b, top+" most referencing gopherholes", s.TopOutDegree)
+
+	if s.Items > 0 {
+		fmt.Fprintf(&b, "\nItems\n")
+		fmt.Fprintf(&b, "  total:  %d\n", s.Items)
+
+		writeCounts(&b, "Item types", s.ItemTypes)
+		writeCounts(&b, top+" gopherholes by resources", s.TopResourcesPerHost)
+		writeCounts(&b, top+" largest menus", s.TopLargestMenus)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCounts writes a section listing the Counts c to b.
+func writeCounts(b *strings.Builder, title string, c []Count) {
+	width := 0
+	for _, e := range c {
+		if len(e.Name) > width {
+			width = len(e.Name)
+		}
+	}
+
+	fmt.Fprintf(b, "\n%s\n", title)
+	for _, e := range c {
+		fmt.Fprintf(b, "  %-*s  %d\n", width, e.Name, e.Count)
+	}
+}
@@ -0,0 +1,149 @@
+package stats
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/itemlog"
+)
+
+var testDotfile = `strict digraph {
+	"a.example.org:70"[alive=true]
+	"b.example.org:70"[alive=true]
+	"c.example.net:7070"[alive=true]
+	"a.example.org:70" -> "b.example.org:70"
+	"a.example.org:70" -> "a.example.org:70"
+	"a.example.org:70" -> "dead.example.org:70"
+	"b.example.org:70" -> "dead.example.com:70"
+	"b.example.org:70" -> "192.0.2.1:70"
+	"c.example.net:7070" -> "[2001:db8::1]:70"
+	"c.example.net:7070" -> "dead.example.com:70"
+}
+`
+
+func testStats(t *testing.T, top int) *Stats {
+	g, err := grawler.ReadGraph(strings.NewReader(testDotfile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return New(g, top)
+}
+
+func TestNew(t *testing.T) {
+	s := testStats(t, 2)
+
+	if s.Alive != 3 || s.Dead != 4 || s.Total != 7 {
+		t.Errorf("Unexpected counts: %d, %d, %d", s.Alive, s.Dead, s.Total)
+	}
+
+	tests := []struct {
+		name     string
+		counts   []Count
+		expected []Count
+	}{
+		{"alive TLDs", s.TopAliveTLDs, []Count{{"org", 2}, {"net", 1}}},
+		{"dead TLDs", s.TopDeadTLDs, []Count{{"com", 1}, {"org", 1}}},
+		{"ports", s.Ports, []Count{{"70", 6}, {"7070", 1}}},
+		{"in-degree", s.TopInDegree, []Count{{"dead.example.com:70", 2}, {"192.0.2.1:70", 1}}},
+		{"out-degree", s.TopOutDegree, []Count{{"a.example.org:70", 2}, {"b.example.org:70", 2}}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.counts, tt.expected) {
+			t.Errorf("%s: %v != %v", tt.name, tt.counts, tt.expected)
+		}
+	}
+
+	expected := Addresses{5, 1, 1}
+	if s.Addresses != expected {
+		t.Errorf("%v != %v", s.Addresses, expected)
+	}
+}
+
+func record(url, typ, host, parent string) itemlog.Record {
+	return itemlog.Record{
+		URL:      url,
+		Type:     typ,
+		TypeName: grawler.ItemType(typ[0]).Name(),
+		Hostname: host,
+		Port:     "70",
+		Parent:   parent,
+	}
+}
+
+func TestAddItems(t *testing.T) {
+	s := testStats(t, 0)
+
+	root := "gopher://a.example.org:70/1"
+	s.AddItems([]itemlog.Record{
+		record("gopher://a.example.org:70/1/x", "1", "a.example.org", root),
+		record("gopher://a.example.org:70/0/y", "0", "a.example.org", root),
+		record("gopher://a.example.org:70/0/y", "0", "a.example.org", root),
+		record("gopher://error.host:70/i", "i", "error.host", root),
+		record("gopher://b.example.org:70/1", "1", "b.example.org", "gopher://a.example.org:70/1/x"),
+	})
+
+	if s.Items != 5 {
+		t.Errorf("%d != %d", s.Items, 5)
+	}
+
+	tests := []struct {
+		name     string
+		counts   []Count
+		expected []Count
+	}{
+		{"item types", s.ItemTypes, []Count{{"directory", 2}, {"text", 2}, {"info", 1}}},
+		{"resources", s.TopResourcesPerHost, []Count{{"a.example.org:70", 2}, {"b.example.org:70", 1}}},
+		{"menus", s.TopLargestMenus, []Count{{root, 4}, {"gopher://a.example.org:70/1/x", 1}}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.counts, tt.expected) {
+			t.Errorf("%s: %v != %v", tt.name, tt.counts, tt.expected)
+		}
+	}
+}
+
+var expectedText = `Gopherholes
+  alive:  3
+  dead:   4
+  total:  7
+
+Top 2 TLDs for alive gopherholes
+  .org   2
+  .net   1
+
+Top 2 TLDs for dead gopherholes
+  .com   1
+  .org   1
+
+Addresses
+  hostname:  5
+  ipv4:      1
+  ipv6:      1
+
+Ports
+  70    6
+  7070  1
+
+Top 2 most referenced gopherholes
+  dead.example.com:70  2
+  192.0.2.1:70         1
+
+Top 2 most referencing gopherholes
+  a.example.org:70  2
+  b.example.org:70  2
+`
+
+func TestWriteText(t *testing.T) {
+	s := testStats(t, 2)
+
+	var b bytes.Buffer
+	if err := s.WriteText(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.String() != expectedText {
+		t.Errorf("%q != %q", b.String(), expectedText)
+	}
+}
@@ -0,0 +1,167 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// ErrNotRecorded is returned by Archive.ReplayOpener for resources that are not
+// part of the Archive.
+var ErrNotRecorded = errors.New("Resource not recorded")
+
+// Archive holds the outcome of every fetch recorded by Writer.Opener, so a
+// crawl can be replayed without network access. If a resource has been
+// recorded multiple times, the last recording is used.
+type Archive struct {
+	records map[string]*Record
+}
+
+// LoadArchive reads the files names, as returned by Files, into an Archive.
+func LoadArchive(names []string) (*Archive, error) {
+	a := &Archive{make(map[string]*Record)}
+
+	err := ReadFiles(names, func(rec *Record) error {
+		switch rec.Type {
+		case ResponseType:
+			a.records[rec.TargetURI] = rec
+		case MetadataType:
+			if _, ok := rec.Fields[ErrorField]; ok {
+				a.records[rec.TargetURI] = rec
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Len returns the number of recorded resources.
+func (a *Archive) Len() int {
+	return len(a.records)
+}
+
+// ReplayOpener is a grawler.ResourceOpener serving the recorded outcome of
+// fetching *grawler.Resource r: recorded responses are served byte by byte,
+// recorded failures are returned as errors with the recorded error message and
+// class, so grawler.ErrorClass, grawler.Transient and grawler.Breaker treat
+// them like the original errors. Resources that have not been recorded yield
+// an error wrapping ErrNotRecorded.
+func (a *Archive) ReplayOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	u, err := r.TryString()
+	if err != nil {
+		return nil, err
+	}
+
+	rec, ok := a.records[u]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotRecorded, u)
+	}
+
+	_, failed := rec.Fields[ErrorField]
+	if rec.Type != ResponseType {
+		return nil, replayedErrorOf(rec)
+	}
+
+	rc := &replayReadCloser{Reader: bytes.NewReader(rec.Content)}
+	if failed {
+		rc.err = replayedErrorOf(rec)
+	}
+	return rc, nil
+}
+
+// replayedError is a failure recorded by Writer.Opener.
+type replayedError struct {
+	msg   string
+	class string // as returned by grawler.ErrorClass
+	dial  bool   // connecting failed
+}
+
+// replayedErrorOf returns the failure recorded in *Record rec.
+func replayedErrorOf(rec *Record) error {
+	return &replayedError{
+		msg:   rec.Fields[ErrorField],
+		class: rec.Fields[ErrorClassField],
+		dial:  rec.Fields[DialErrorField] == "true",
+	}
+}
+
+func (e *replayedError) Error() string {
+	return e.msg
+}
+
+// Timeout and Temporary implement net.Error.
+func (e *replayedError) Timeout() bool {
+	return e.class == grawler.ErrorClassTimeout
+}
+
+func (e *replayedError) Temporary() bool {
+	return e.Timeout()
+}
+
+// Is reports whether target is one of the sentinel errors of package grawler
+// matched by the recorded error.
+func (e *replayedError) Is(target error) bool {
+	switch target {
+	case grawler.ErrDial:
+		return e.dial
+	case grawler.ErrTimeout:
+		return e.dial && e.Timeout()
+	case grawler.ErrHostBroken:
+		return e.class == grawler.ErrorClassBroken
+	}
+	return false
+}
+
+// Unwrap returns an error of the recorded class.
+func (e *replayedError) Unwrap() error {
+	switch e.class {
+	case grawler.ErrorClassRefused:
+		return syscall.ECONNREFUSED
+	case grawler.ErrorClassReset:
+		return syscall.ECONNRESET
+	case grawler.ErrorClassUnreachable:
+		return syscall.EHOSTUNREACH
+	case grawler.ErrorClassDNSNotFound:
+		return &net.DNSError{Err: e.msg, IsNotFound: true}
+	case grawler.ErrorClassDNS:
+		return &net.DNSError{Err: e.msg, IsTemporary: true}
+	case grawler.ErrorClassTooLong:
+		return bufio.ErrTooLong
+	}
+	return nil
+}
+
+// replayReadCloser serves recorded content. If err is not nil, it is returned
+// instead of io.EOF after the content has been read.
+type replayReadCloser struct {
+	*bytes.Reader
+	err error
+}
+
+func (r *replayReadCloser) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF && r.err != nil {
+		err = r.err
+	}
+	return n, err
+}
+
+func (r *replayReadCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package warc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// failingReader returns its content and then a read error.
+type failingReader struct {
+	mockConn
+	err error
+}
+
+func (f failingReader) Read(p []byte) (int, error) {
+	n, err := f.mockConn.Read(p)
+	if err == io.EOF {
+		err = f.err
+	}
+	return n, err
+}
+
+var (
+	errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	errReset   = &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	errTimeout = &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}
+)
+
+func flakyOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	switch r.Hostname {
+	case "dead":
+		return nil, &grawler.DialError{Host: r.Host, Err: errRefused}
+	case "flaky":
+		return failingReader{mockConn{strings.NewReader("1Partial\t/p\tlocalhost\t70\r\n")}, errReset}, nil
+	case "slow":
+		return failingReader{mockConn{strings.NewReader("")}, errTimeout}, nil
+	}
+	return mockOpener(r)
+}
+
+// crawlAll crawls all resources using grawler.ResourceOpener o and returns a
+// description of the outcome of every crawl.
+func crawlAll(o grawler.ResourceOpener, resources []*grawler.Resource) []string {
+	var outcomes []string
+	for _, r := range resources {
+		findings := make(chan *grawler.CrawlFinding, 10)
+		err := grawler.ResourceCrawler(o, r, findings)
+		close(findings)
+
+		s := fmt.Sprintf("%v: err=%v class=%s dial=%v transient=%v", r, err,
+			grawler.ErrorClass(err), errors.Is(err, grawler.ErrDial), grawler.Transient(err))
+		for f := range findings {
+			s += fmt.Sprintf(" %v", f.Resource)
+		}
+		outcomes = append(outcomes, s)
+	}
+	return outcomes
+}
+
+func TestReplay(t *testing.T) {
+	resources := []*grawler.Resource{
+		{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""},
+		{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: "/dir"},
+		{Host: &grawler.Host{Hostname: "dead", Port: "70"}, Type: '1', Selector: ""},
+		// Rejected by the circuit breaker.
+		{Host: &grawler.Host{Hostname: "dead", Port: "70"}, Type: '1', Selector: "/other"},
+		{Host: &grawler.Host{Hostname: "flaky", Port: "70"}, Type: '1', Selector: ""},
+		{Host: &grawler.Host{Hostname: "slow", Port: "70"}, Type: '1', Selector: ""},
+	}
+
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "grawler", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	b := grawler.NewBreaker(1, time.Hour)
+	recorded := crawlAll(b.Opener(w.Opener(flakyOpener)), resources)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	names, err := Files(dir, "grawler")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	a, err := LoadArchive(names)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The resource rejected by the circuit breaker has not been fetched.
+	if a.Len() != len(resources)-1 {
+		t.Fatalf("Unexpected number of recorded resources: %d != %d", a.Len(), len(resources)-1)
+	}
+
+	classes := []string{"", "", grawler.ErrorClassRefused, grawler.ErrorClassBroken,
+		grawler.ErrorClassReset, grawler.ErrorClassTimeout}
+	for i, c := range classes {
+		if !strings.Contains(recorded[i], "class="+c+" ") {
+			t.Errorf("Unexpected recorded outcome: %q", recorded[i])
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		b := grawler.NewBreaker(1, time.Hour)
+		replayed := crawlAll(b.Opener(a.ReplayOpener), resources)
+		if strings.Join(replayed, "\n") != strings.Join(recorded, "\n") {
+			t.Errorf("Replay #%d differs: %q != %q", i, replayed, recorded)
+		}
+	}
+
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "unknown", Port: "70"}, Type: '1', Selector: ""}
+	_, err = a.ReplayOpener(r)
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Unexpected error for unrecorded resource: %v", err)
+	}
+}
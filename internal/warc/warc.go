@@ -0,0 +1,524 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+// Archiving of fetched gopher resources in the WARC format.
+//
+// Every fetch is stored as a request record, holding the selector sent, and a
+// response record, holding the raw bytes received. Records are written to
+// rotating, gzip compressed files, one gzip member per record.
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+)
+
+// Version is the WARC version written.
+const Version = "WARC/1.1"
+
+// Record types used by grawler.
+const (
+	WarcinfoType = "warcinfo"
+	RequestType  = "request"
+	ResponseType = "response"
+	MetadataType = "metadata"
+)
+
+// ErrorField is the header field recording the error of a failed fetch. It is
+// set in response records if reading the response failed, and in metadata
+// records if the resource could not be opened at all.
+const ErrorField = "WARC-Gopher-Error"
+
+// ErrorClassField is the header field recording the class of the error of a
+// failed fetch, as returned by grawler.ErrorClass. DialErrorField is set to
+// "true" if the resource could not be opened because connecting failed.
+const (
+	ErrorClassField = "WARC-Gopher-Error-Class"
+	DialErrorField  = "WARC-Gopher-Dial-Error"
+)
+
+// Content types of gopher request and response records.
+const (
+	RequestContentType  = "application/x-gopher-request"
+	ResponseContentType = "application/x-gopher-response"
+)
+
+// Record is a WARC record. Fields holds all header fields without a dedicated
+// struct field.
+type Record struct {
+	Type         string
+	ID           string
+	Date         time.Time
+	TargetURI    string
+	IPAddress    string
+	ConcurrentTo string
+	ContentType  string
+	Fields       map[string]string
+	Content      []byte
+}
+
+// NewRecordID returns a new, random record ID.
+func NewRecordID() string {
+	var u [16]byte
+	_, err := rand.Read(u[:])
+	if err != nil {
+		panic(err)
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// WriteTo writes the uncompressed Record to w.
+func (r *Record) WriteTo(w io.Writer) (int64, error) {
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, "%s\r\n", Version)
+	field := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(&b, "%s: %s\r\n", k, v)
+		}
+	}
+	field("WARC-Type", r.Type)
+	field("WARC-Record-ID", r.ID)
+	field("WARC-Date", r.Date.UTC().Format(time.RFC3339Nano))
+	field("WARC-Target-URI", r.TargetURI)
+	field("WARC-IP-Address", r.IPAddress)
+	field("WARC-Concurrent-To", r.ConcurrentTo)
+	field("Content-Type", r.ContentType)
+
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		field(k, r.Fields[k])
+	}
+
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(r.Content))
+	b.Write(r.Content)
+	b.WriteString("\r\n\r\n")
+
+	return b.WriteTo(w)
+}
+
+// Writer writes Records to rotating, gzip compressed files in a directory. A
+// new file is started as soon as the current file exceeds a maximum size. A
+// Writer is safe for concurrent use.
+type Writer struct {
+	dir     string
+	prefix  string
+	maxSize int64
+
+	mtx  sync.Mutex
+	f    *os.File
+	size int64
+	seq  int
+	now  func() time.Time
+}
+
+// NewWriter creates a new Writer writing to files named
+// prefix-timestamp-sequence.warc.gz in directory dir. Files are rotated after
+// maxSize compressed bytes, a maxSize of zero disables rotation.
+func NewWriter(dir, prefix string, maxSize int64) (*Writer, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		dir:     dir,
+		prefix:  prefix,
+		maxSize: maxSize,
+		now:     time.Now,
+	}, nil
+}
+
+// Write writes Record r as a gzip member to the current file.
+func (w *Writer) Write(r *Record) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.f != nil && w.maxSize > 0 && w.size >= w.maxSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	if w.f == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+
+	return w.write(r)
+}
+
+// write writes Record r to the current file. The caller has to hold w.mtx.
+func (w *Writer) write(r *Record) error {
+	cw := &countingWriter{w: w.f}
+	gz := gzip.NewWriter(cw)
+	_, err := r.WriteTo(gz)
+	if err != nil {
+		return err
+	}
+	err = gz.Close()
+	w.size += cw.n
+	return err
+}
+
+// open opens the next file and writes a warcinfo record to it. The caller has
+// to hold w.mtx.
+func (w *Writer) open() error {
+	now := w.now()
+	name := fmt.Sprintf("%s-%s-%05d.warc.gz", w.prefix, now.UTC().Format("20060102150405"), w.seq)
+	w.seq++
+
+	f, err := os.OpenFile(filepath.Join(w.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.size = 0
+
+	return w.write(&Record{
+		Type:        WarcinfoType,
+		ID:          NewRecordID(),
+		Date:        now,
+		ContentType: "application/warc-fields",
+		Fields:      map[string]string{"WARC-Filename": name},
+		Content:     []byte("software: grawler\r\nformat: WARC File Format 1.1\r\n"),
+	})
+}
+
+// rotate closes the current file. The caller has to hold w.mtx.
+func (w *Writer) rotate() error {
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	return w.rotate()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Opener returns a grawler.ResourceOpener that opens resources using
+// grawler.ResourceOpener o and writes a request and a response record for
+// every resource when it is closed. The response holds everything read from
+// the resource. If the io.ReadCloser returned by o provides a RemoteAddr
+// method, like net.Conn does, the peer IP address is recorded as well.
+//
+// Failures are recorded too, so they can be replayed by Archive: if the
+// resource can not be opened, a metadata record is written; if reading fails,
+// the error is recorded in the response record. Resources rejected by a
+// grawler.Breaker wrapped by o have not been fetched and are not recorded, a
+// Breaker wrapping the ReplayOpener of an Archive rejects them again.
+//
+// Errors writing the records are returned when closing the resource, or
+// together with the error opening the resource.
+func (w *Writer) Opener(o grawler.ResourceOpener) grawler.ResourceOpener {
+	return func(r *grawler.Resource) (io.ReadCloser, error) {
+		start := w.now()
+		rc, err := o(r)
+		if errors.Is(err, grawler.ErrHostBroken) {
+			return nil, err
+		}
+		if err != nil {
+			if u, uerr := r.TryString(); uerr == nil {
+				fields := errorFields(err)
+				if errors.Is(err, grawler.ErrDial) {
+					fields[DialErrorField] = "true"
+				}
+				werr := w.Write(&Record{
+					Type:      MetadataType,
+					ID:        NewRecordID(),
+					Date:      start,
+					TargetURI: u,
+					Fields:    fields,
+				})
+				if werr != nil {
+					err = fmt.Errorf("%w (could not record failure: %v)", err, werr)
+				}
+			}
+			return nil, err
+		}
+		return &recordingReadCloser{rc: rc, w: w, r: r, start: start}, nil
+	}
+}
+
+// errorFields returns the header fields recording err.
+func errorFields(err error) map[string]string {
+	return map[string]string{
+		ErrorField:      oneLine(err.Error()),
+		ErrorClassField: grawler.ErrorClass(err),
+	}
+}
+
+// oneLine makes s usable as header field value.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+type recordingReadCloser struct {
+	rc    io.ReadCloser
+	w     *Writer
+	r     *grawler.Resource
+	start time.Time
+	buf   bytes.Buffer
+	err   error
+}
+
+func (t *recordingReadCloser) Read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	t.buf.Write(p[:n])
+	if err != nil && err != io.EOF {
+		t.err = err
+	}
+	return n, err
+}
+
+func (t *recordingReadCloser) Close() error {
+	ip := ""
+	if a, ok := t.rc.(interface{ RemoteAddr() net.Addr }); ok && a.RemoteAddr() != nil {
+		if host, _, err := net.SplitHostPort(a.RemoteAddr().String()); err == nil {
+			ip = host
+		}
+	}
+
+	err := t.rc.Close()
+
+	u, uerr := t.r.TryString()
+	if uerr != nil {
+		return uerr
+	}
+
+	req := &Record{
+		Type:        RequestType,
+		ID:          NewRecordID(),
+		Date:        t.start,
+		TargetURI:   u,
+		IPAddress:   ip,
+		ContentType: RequestContentType,
+		Content:     []byte(t.r.Selector + "\r\n"),
+	}
+	resp := &Record{
+		Type:         ResponseType,
+		ID:           NewRecordID(),
+		Date:         t.start,
+		TargetURI:    u,
+		IPAddress:    ip,
+		ConcurrentTo: req.ID,
+		ContentType:  ResponseContentType,
+		Fields: map[string]string{
+			"WARC-Gopher-Completed": t.w.now().UTC().Format(time.RFC3339Nano),
+		},
+		Content: t.buf.Bytes(),
+	}
+	if t.err != nil {
+		for k, v := range errorFields(t.err) {
+			resp.Fields[k] = v
+		}
+	}
+
+	for _, rec := range []*Record{req, resp} {
+		if werr := t.w.Write(rec); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// Reader reads Records from a WARC file. Compressed and uncompressed files are
+// supported.
+type Reader struct {
+	br *bufio.Reader
+}
+
+// NewReader creates a new Reader reading from r. Gzip compressed input is
+// detected automatically.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(gz)
+	}
+
+	return &Reader{br}, nil
+}
+
+// Next reads the next Record. At the end of the input io.EOF is returned.
+func (r *Reader) Next() (*Record, error) {
+	line, err := r.readLine()
+	for err == nil && line == "" {
+		line, err = r.readLine()
+	}
+	if err == io.EOF && line == "" {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "WARC/") {
+		return nil, fmt.Errorf("Invalid WARC record: %q", line)
+	}
+
+	rec := &Record{Fields: make(map[string]string)}
+	length := int64(-1)
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		if line == "" {
+			break
+		}
+
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			return nil, fmt.Errorf("Invalid WARC header field: %q", line)
+		}
+		k, v := line[:i], strings.TrimSpace(line[i+1:])
+
+		switch strings.ToLower(k) {
+		case "warc-type":
+			rec.Type = v
+		case "warc-record-id":
+			rec.ID = v
+		case "warc-date":
+			rec.Date, err = time.Parse(time.RFC3339Nano, v)
+		case "warc-target-uri":
+			rec.TargetURI = v
+		case "warc-ip-address":
+			rec.IPAddress = v
+		case "warc-concurrent-to":
+			rec.ConcurrentTo = v
+		case "content-type":
+			rec.ContentType = v
+		case "content-length":
+			length, err = strconv.ParseInt(v, 10, 64)
+		default:
+			rec.Fields[k] = v
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid WARC header field %q: %v", line, err)
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("WARC record without Content-Length: %s", rec.ID)
+	}
+
+	rec.Content = make([]byte, length)
+	_, err = io.ReadFull(r.br, rec.Content)
+	if err != nil {
+		return nil, unexpected(err)
+	}
+
+	return rec, nil
+}
+
+// readLine reads a line and strips the line terminator.
+func (r *Reader) readLine() (string, error) {
+	line, err := r.br.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Files returns the names of all files in directory dir written by a Writer
+// using prefix, in the order they have been written.
+func Files(dir, prefix string) ([]string, error) {
+	names, err := filepath.Glob(filepath.Join(dir, prefix+"-*.warc.gz"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadFiles reads all Records from the files names, in order, and calls fn for
+// each of them. If fn returns an error, reading stops and the error is
+// returned.
+func ReadFiles(names []string, fn func(*Record) error) error {
+	for _, name := range names {
+		err := readFile(name, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readFile(name string, fn func(*Record) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	for {
+		rec, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
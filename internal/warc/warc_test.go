@@ -0,0 +1,207 @@
+package warc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/mirror"
+)
+
+type mockConn struct {
+	*strings.Reader
+}
+
+func (mockConn) Close() error {
+	return nil
+}
+
+func (mockConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 70}
+}
+
+var warcContent = map[string]string{
+	"":     "1Dir\t/dir\tlocalhost\t70\r\n.\r\n",
+	"/dir": "0File\t/dir/file\tlocalhost\t70\r\n.\r\n",
+}
+
+func mockOpener(r *grawler.Resource) (io.ReadCloser, error) {
+	s, ok := warcContent[r.Selector]
+	if !ok {
+		return nil, fmt.Errorf("Not found: %v", r)
+	}
+	return mockConn{strings.NewReader(s)}, nil
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	rec := &Record{
+		Type:        ResponseType,
+		ID:          NewRecordID(),
+		Date:        time.Date(2016, 1, 2, 3, 4, 5, 6, time.UTC),
+		TargetURI:   "gopher://localhost:70/1",
+		IPAddress:   "::1",
+		ContentType: ResponseContentType,
+		Fields:      map[string]string{"X-Test": "yes"},
+		Content:     []byte("binary\x00content\r\n\r\n"),
+	}
+
+	var b bytes.Buffer
+	if _, err := rec.WriteTo(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := rec.WriteTo(&b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r, err := NewReader(&b)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.Type != rec.Type || got.ID != rec.ID || !got.Date.Equal(rec.Date) ||
+			got.TargetURI != rec.TargetURI || got.IPAddress != rec.IPAddress ||
+			got.ContentType != rec.ContentType || got.Fields["X-Test"] != "yes" ||
+			!bytes.Equal(got.Content, rec.Content) {
+			t.Errorf("Unexpected record: %#v != %#v", got, rec)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Unexpected error at end of input: %v", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	s := "WARC/1.1\r\nWARC-Type: response\r\nContent-Length: 100\r\n\r\nshort"
+	r, err := NewReader(strings.NewReader(s))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := r.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestOpener(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "grawler", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	findings := make(chan *grawler.CrawlFinding, 10)
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""}
+	if err := grawler.ResourceCrawler(w.Opener(mockOpener), r, findings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	f := <-findings
+	if err := grawler.ResourceCrawler(w.Opener(mockOpener), f.Resource, findings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	names, err := Files(dir, "grawler")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(names) != 4 {
+		t.Errorf("Files not rotated: %v", names)
+	}
+
+	var got []string
+	requests := make(map[string]string)
+	err = ReadFiles(names, func(rec *Record) error {
+		switch rec.Type {
+		case RequestType:
+			requests[rec.ID] = string(rec.Content)
+		case ResponseType:
+			got = append(got, fmt.Sprintf("%s %s %q %q", rec.TargetURI, rec.IPAddress,
+				requests[rec.ConcurrentTo], rec.Content))
+			if rec.Fields["WARC-Gopher-Completed"] == "" {
+				t.Errorf("Completion timestamp missing: %#v", rec)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{
+		fmt.Sprintf("gopher://localhost:70/1 192.0.2.1 %q %q", "\r\n", warcContent[""]),
+		fmt.Sprintf("gopher://localhost:70/1/dir 192.0.2.1 %q %q", "/dir\r\n", warcContent["/dir"]),
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("%q != %q", got, expected)
+	}
+}
+
+func TestOpenerMirrored(t *testing.T) {
+	m, err := mirror.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "grawler", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	findings := make(chan *grawler.CrawlFinding, 10)
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: ""}
+	if err := grawler.ResourceCrawler(w.Opener(m.Opener(mockOpener)), r, findings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	names, err := Files(dir, "grawler")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = ReadFiles(names, func(rec *Record) error {
+		if rec.Type == ResponseType && rec.IPAddress != "192.0.2.1" {
+			t.Errorf("%q != %q", rec.IPAddress, "192.0.2.1")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestOpenerRecordFailureError(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWriter(dir, "grawler", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	r := &grawler.Resource{Host: &grawler.Host{Hostname: "localhost", Port: "70"}, Type: '1', Selector: "/missing"}
+	_, err = w.Opener(mockOpener)(r)
+	if err == nil || !strings.Contains(err.Error(), "could not record failure") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	errOpen := errors.New("Not found")
+	_, err = w.Opener(func(*grawler.Resource) (io.ReadCloser, error) { return nil, errOpen })(r)
+	if !errors.Is(err, errOpen) {
+		t.Errorf("%v is not %v", err, errOpen)
+	}
+}
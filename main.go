@@ -14,37 +14,50 @@
 //
 // There are some commandline flags with sensible defaults available. Try the
 // -h flag to get a list of these flags.
+//
+// Besides crawling, grawler knows some commands working on the results of
+// previous crawls:
+//
+//	grawler search [flags] terms...   search the index of crawled items
+//	grawler serve [flags]             serve the results as a gopher server
+//	grawler diff [flags] old new      report the changes between two crawls
+//	grawler stats [flags] dotfile     report statistics of a crawl
+//	grawler rank [flags] dotfile      rank the servers found by a crawl
+//	grawler filter [flags] dotfile    post-process the results of a crawl
+//	grawler monitor [flags]           monitor the liveness of known servers
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/blabber/grawler/internal/grawler"
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/analytics"
+	"github.com/blabber/grawler/internal/eventlog"
+	"github.com/blabber/grawler/internal/index"
+	"github.com/blabber/grawler/internal/itemlog"
+	"github.com/blabber/grawler/internal/metrics"
+	"github.com/blabber/grawler/internal/mirror"
+	"github.com/blabber/grawler/internal/warc"
 )
 
 // blacklist some selectors. Any selector containing one of these substrings
 // will not be crawled. These selectors tend to belong to "interactive" games,
 // yielding endless crawls.
-var blacklist = []string{
+var blacklist = grawler.Blacklist{
 	".run*",
 	".cgi?",
 }
 
-// crawledJob is used in the function main to communicate the finished job and
-// a crawlerID identifying the ResourceCrawler that finished the job through
-// the done channel.
-type crawledJob struct {
-	crawlerID int
-	job       *grawler.Resource
-}
-
 // mustCreateFile creates a file named name and panics if the creation fails.
 func mustCreateFile(name string) *os.File {
 	f, err := os.Create(name)
@@ -54,139 +67,501 @@ func mustCreateFile(name string) *os.File {
 	return f
 }
 
+// commands maps the names of the grawler commands to their implementations.
+// An implementation gets the command line arguments following the command
+// name.
+var commands = map[string]func(args []string){
+	"search":  search,
+	"stats":   stats,
+	"rank":    rank,
+	"filter":  filter,
+	"serve":   serve,
+	"diff":    diff,
+	"monitor": monitorHosts,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
+	crawl()
+}
+
+// crawl crawls the gopherspace. This is the default if no command is given.
+func crawl() {
 	// Parse flags
 	flagBootstrap := flag.String("bootstrap", "gopher.floodgap.com", "the first server to crawl")
 	flagPort := flag.String("port", "70", "the listening port of the first server to crawl")
 	flagCrawlers := flag.Int("crawlers", runtime.NumCPU(), "the number of crawlers to run concurrently")
 	flagDotfile := flag.String("dotfile", "grawler.dot", "the output file")
 	flagLogfile := flag.String("logfile", "", "the log file (empty for stderr)")
+	flagLogFormat := flag.String("log-format", "text", "the log format: \"text\" or \"json\" (one event per line)")
 	flagItemsLogfile := flag.String("ilogfile", "", "the log file for items (\"-\" for stdout), empty to disable item logging")
+	flagItemsLogFormat := flag.String("ilog-format", itemlog.FormatURL, "the format of the item log: "+strings.Join(itemlog.Formats, ", "))
+	flagIndex := flag.String("index", "", "the file to write the in-memory index of crawled items to, empty to disable indexing")
+	flagIndexInterval := flag.Duration("index-interval", 5*time.Minute, "the interval at which the index is written during the crawl, 0 to write it only at the end")
+	flagMirror := flag.String("mirror", "", "the directory to mirror all fetched menus to, empty to disable mirroring")
+	flagMirrorTypes := flag.String("mirror-types", "", "the item types to fetch and mirror besides menus, e.g. \"0\" for text files or \"09gI\" for text and binary files")
+	flagMirrorMaxBytes := flag.Int64("mirror-max-bytes", mirror.DefaultMaxBytes, "the maximum size of the items fetched by -mirror-types, 0 for no limit")
+	flagWarc := flag.String("warc", "", "the directory to write WARC archives of all fetched menus to, empty to disable archiving")
+	flagWarcSize := flag.Int64("warc-size", 1024, "the size in MB after which a new WARC archive is started")
+	flagReplay := flag.String("replay", "", "the directory holding WARC archives of a previous crawl to replay instead of accessing the network")
+	flagMetrics := flag.String("metrics", "", "the address to serve Prometheus metrics on at /metrics, e.g. \":9100\", empty to disable")
+	flagBreakerThreshold := flag.Int("breaker-threshold", 3, "consecutive connect failures after which a server is considered broken and its resources fail fast, 0 to disable")
+	flagBreakerCooldown := flag.Duration("breaker-cooldown", 5*time.Minute, "the time after which a broken server is probed again")
+	flagRetries := flag.Int("retries", 2, "the number of retries of jobs failing with transient errors (timeouts, resets, temporary DNS failures)")
+	flagRetryDelay := flag.Duration("retry-delay", 30*time.Second, "the delay before the first retry, doubled for every further retry")
+	flagRetryMaxDelay := flag.Duration("retry-max-delay", 10*time.Minute, "the maximum delay before a retry")
+	flagMaxBytes := flag.Int64("max-bytes", 0, "the maximum number of bytes read from a menu or an item fetched by -mirror-types, 0 for no limit")
+	flagMaxLineLength := flag.Int("max-line-length", grawler.DefaultMaxLineLength, "the maximum length of a menu line")
+	flagMaxItems := flag.Int("max-items", 0, "the maximum number of lines read from a menu, 0 for no limit")
+	graphFilters := addGraphFilterFlags(flag.CommandLine)
+	flagAnalytics := flag.Bool("analytics", false, "annotate the nodes of the dotfile with PageRank, degrees, betweenness, components and distance from the bootstrap server after crawling")
+	flagVisited := flag.String("visited", "map", "where to keep the crawled resources and graphed relations: \"map\" (exact, in memory), \"disk\" (exact, in temporary files) or \"bloom\" (probabilistic, fixed memory)")
+	flagVisitedDir := flag.String("visited-dir", "", "the directory for the temporary files of -visited disk, empty for the default temporary directory")
+	flagVisitedCapacity := flag.Int("visited-capacity", 10000000, "the expected number of resources or relations for -visited bloom")
+	flagVisitedFPRate := flag.Float64("visited-fp-rate", 0.001, "the false positive rate for -visited bloom, false positives skip resources or relations")
+	flagAliases := flag.String("aliases", "", "resolve host aliases after crawling, not with -replay: \"sameas\" to add sameAs edges, \"merge\" to merge nodes, empty to disable")
 	flag.Parse()
 
+	if *flagAliases != "" && *flagAliases != "sameas" && *flagAliases != "merge" {
+		fmt.Fprintf(os.Stderr, "Invalid value for -aliases: %q\n", *flagAliases)
+		os.Exit(2)
+	}
+	if *flagVisited != "map" && *flagVisited != "disk" && *flagVisited != "bloom" {
+		fmt.Fprintf(os.Stderr, "Invalid value for -visited: %q\n", *flagVisited)
+		os.Exit(2)
+	}
+	if *flagLogFormat != "text" && *flagLogFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Invalid value for -log-format: %q\n", *flagLogFormat)
+		os.Exit(2)
+	}
+
 	// Setup logging
+	var logOutput io.Writer = os.Stderr
 	if *flagLogfile != "" {
-		log.SetOutput(mustCreateFile(*flagLogfile))
+		logOutput = mustCreateFile(*flagLogfile)
+	}
+
+	var events eventlog.Logger
+	if *flagLogFormat == "json" {
+		events = eventlog.NewJSON(logOutput)
+		log.SetFlags(0)
+		log.SetOutput(eventlog.MessageWriter(events))
+	} else {
+		log.SetOutput(logOutput)
+		events = eventlog.NewText(log.Default())
 	}
 
 	// Setup item log
 	var itemActions []grawler.ItemActionFunc
 
+	var ilog *itemlog.Writer
 	if *flagItemsLogfile != "" {
-		f := os.Stdout
+		// Hide the Close method of os.Stdout from the itemlog.Writer.
+		var f io.Writer = struct{ io.Writer }{os.Stdout}
 		if *flagItemsLogfile != "-" {
 			f = mustCreateFile(*flagItemsLogfile)
 		}
 
-		var mtx sync.Mutex
-		itemActions = append(itemActions, func(r grawler.Resource) {
-			mtx.Lock()
-			defer mtx.Unlock()
+		var err error
+		ilog, err = itemlog.NewWriter(f, *flagItemsLogFormat)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		ilog.OnError = func(err error) {
+			log.Printf("[ia] ERR: %v", err)
+		}
+		itemActions = append(itemActions, ilog.Action)
+	}
+
+	// Setup index
+	var idx *index.Index
+	if *flagIndex != "" {
+		idx = index.New()
+		itemActions = append(itemActions, idx.Add)
+
+		if *flagIndexInterval > 0 {
+			go func() {
+				for range time.Tick(*flagIndexInterval) {
+					if err := idx.Save(*flagIndex); err != nil {
+						log.Printf("ERR: Could not save index: %v", err)
+					}
+				}
+			}()
+		}
+	}
+
+	// Setup replay
+	base := grawler.ResourceOpener(grawler.NetResourceOpener)
+	if *flagReplay != "" {
+		names, err := warc.Files(*flagReplay, "grawler")
+		if err != nil {
+			panic(err)
+		}
+		archive, err := warc.LoadArchive(names)
+		if err != nil {
+			panic(err)
+		}
+		log.Printf("Replaying %d resources from %d archives", archive.Len(), len(names))
+		base = archive.ReplayOpener
+	}
+
+	// Setup mirror
+	var mirr *mirror.Mirror
+	if *flagMirror != "" {
+		var err error
+		mirr, err = mirror.Open(*flagMirror)
+		if err != nil {
+			panic(err)
+		}
+		mirr.MaxBytes = *flagMirrorMaxBytes
+		if *flagMaxBytes > 0 && (mirr.MaxBytes <= 0 || *flagMaxBytes < mirr.MaxBytes) {
+			mirr.MaxBytes = *flagMaxBytes
+		}
+		mirr.OnError = func(err error) {
+			log.Printf("[mirror] ERR: %v", err)
+		}
+	}
+
+	// Setup WARC archive
+	var warcWriter *warc.Writer
+	if *flagWarc != "" {
+		var err error
+		warcWriter, err = warc.NewWriter(*flagWarc, "grawler", *flagWarcSize<<20)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Setup circuit breaker
+	var breaker *grawler.Breaker
+	if *flagBreakerThreshold > 0 {
+		breaker = grawler.NewBreaker(*flagBreakerThreshold, *flagBreakerCooldown)
+	}
+
+	// Setup metrics
+	met := metrics.NewCrawl()
+	if *flagMetrics != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", met.Handler())
+		go func() {
+			log.Printf("ERR: Metrics endpoint failed: %v", http.ListenAndServe(*flagMetrics, mux))
+		}()
+	}
+
+	// chain wraps a ResourceOpener in the WARC archive, the circuit breaker
+	// and the metrics. The circuit breaker wraps the WARC archive, so only
+	// resources that have actually been fetched are recorded. Replayed
+	// crawls reject the same resources, their breaker sees the same
+	// failures.
+	chain := func(o grawler.ResourceOpener) grawler.ResourceOpener {
+		if warcWriter != nil {
+			o = warcWriter.Opener(o)
+		}
+		if breaker != nil {
+			o = breaker.Opener(o)
+		}
+		return met.Opener(o)
+	}
+
+	// Menus are mirrored while they are crawled, other items are fetched
+	// through the same chain by the ItemAction of the mirror.
+	opener := base
+	if mirr != nil {
+		opener = mirr.Opener(opener)
+		if *flagMirrorTypes != "" {
+			itemActions = append(itemActions,
+				mirr.ItemAction(chain(base), *flagMirrorTypes))
+		}
+	}
+	opener = chain(opener)
 
-			s, err := r.TryString()
+	limits := grawler.Limits{
+		MaxBytes:      *flagMaxBytes,
+		MaxLineLength: *flagMaxLineLength,
+		MaxItems:      *flagMaxItems,
+	}
+
+	// Setup visited sets
+	var diskSets []*grawler.DiskSet
+	newVisited := func() grawler.VisitedSet {
+		switch *flagVisited {
+		case "disk":
+			d, err := grawler.NewDiskSet(*flagVisitedDir)
 			if err != nil {
-				log.Printf("[ia] ERR: %v", err)
-				return
+				panic(err)
 			}
-
-			fmt.Fprintf(f, "%s\n", s)
-		})
+			diskSets = append(diskSets, d)
+			return d
+		case "bloom":
+			return grawler.NewBloomSet(*flagVisitedCapacity, *flagVisitedFPRate)
+		}
+		return grawler.NewMapSet()
 	}
 
 	// Create Coordinator
-	coord := grawler.NewCoordinator()
+	coord := grawler.NewCoordinatorWithVisited(newVisited())
+	retryPolicy := &grawler.RetryPolicy{
+		MaxAttempts: *flagRetries + 1,
+		BaseDelay:   *flagRetryDelay,
+		MaxDelay:    *flagRetryMaxDelay,
+		Jitter:      0.5,
+	}
 
 	// Initialize Grapher
-	grapher, err := grawler.NewGrapher(mustCreateFile(*flagDotfile))
+	// The alive servers are always kept exact, a false positive of a
+	// BloomSet would make a server look dead.
+	grapher, err := grawler.NewGrapherWithVisited(mustCreateFile(*flagDotfile), grawler.NewMapSet(), newVisited())
 	if err != nil {
 		panic(err)
 	}
-	defer func() {
-		err := grapher.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
+	grapher.DropSelfLoops = *graphFilters.dropSelfLoops
 
-	// Create channels and seed crawlers.
-	done := make(chan *crawledJob)
-	findings := make(chan *grawler.CrawlFinding)
-	idleCrawlers := make(chan int, *flagCrawlers)
-	for i := 0; i < *flagCrawlers; i++ {
-		idleCrawlers <- i + 1
+	// graphFailed logs the first error writing the dotfile. The crawl goes
+	// on regardless, later errors are not logged again.
+	var graphErr error
+	graphFailed := func(err error) {
+		if err == nil || graphErr != nil {
+			return
+		}
+		graphErr = err
+		log.Printf("ERR: Could not write dotfile: %v", err)
 	}
 
-	ticks := time.Tick(time.Minute)
-
-	// Bootstrap the crawling.
+	// Log the status of the crawl every minute.
 	go func() {
-		h := *flagBootstrap
-		p := *flagPort
-		findings <- &grawler.CrawlFinding{
-			Resource: &grawler.Resource{
-				Host: &grawler.Host{
-					Hostname: h,
-					Port:     p,
-				},
-				Type:     grawler.DirectoryType,
-				Selector: "",
-			},
-			Parent: nil}
+		for range time.Tick(time.Minute) {
+			queued, active, finished := coord.Counts()
+			events.Log(eventlog.Event{
+				Type: eventlog.Status,
+				Jobs: &eventlog.Jobs{Queued: queued, Active: active, Finished: finished},
+			})
+		}
 	}()
 
-	// Enter the main loop.
-	for {
-		select {
-		case i := <-idleCrawlers:
-			j := coord.QueuedJob()
-			go func() {
-				defer func() {
-					done <- &crawledJob{crawlerID: i, job: j}
-				}()
-
-				if j == nil {
-					return
-				}
-
-				log.Printf("[%d] Crawling %v", i, j)
-				err := grawler.ResourceCrawler(grawler.NetResourceOpener, j, findings, itemActions...)
-				if err != nil {
-					log.Printf("[%d] ERR: %v", i, err)
-				}
-				log.Printf("[%d] Done crawling %v", i, j)
-			}()
-		case f := <-findings:
-			blacklisted := false
-			for _, b := range blacklist {
-				if strings.Contains(f.Resource.Selector, b) {
-					blacklisted = true
-					break
-				}
+	crawler := &grawler.Crawler{
+		Opener:      opener,
+		Workers:     *flagCrawlers,
+		Limits:      limits,
+		ItemActions: itemActions,
+		Retry:       retryPolicy,
+		Blacklist:   blacklist,
+		OnJobStarted: func(worker int, r *grawler.Resource) {
+			met.ActiveCrawlers.Add(1)
+			events.Log(eventlog.Event{Type: eventlog.JobStarted, Crawler: worker, URL: r.String()})
+		},
+		OnJobFinished: func(res *grawler.JobResult) {
+			e := eventlog.Event{
+				Type:     eventlog.JobFinished,
+				Crawler:  res.Worker,
+				URL:      res.Resource.String(),
+				Duration: res.Duration.Seconds(),
+				Bytes:    res.Bytes,
+				Items:    res.Items,
+				Attempt:  res.Attempt,
 			}
-			if blacklisted {
-				log.Printf("Blacklisted: %q", f.Resource.Selector)
-				break
+			if res.Err != nil {
+				met.Error(res.Err)
+				e.Error = res.Err.Error()
+				e.ErrorClass = grawler.ErrorClass(res.Err)
 			}
+			events.Log(e)
+			met.ActiveCrawlers.Add(-1)
+		},
+		OnFinding: func(f *grawler.CrawlFinding, err error) error {
+			defer met.SetJobs(coord.Counts())
 
-			err := coord.QueueJob(f.Resource)
-			if err != nil {
-				log.Print(err)
+			met.Findings.Inc()
+			e := eventlog.Event{Type: eventlog.Finding, URL: f.Resource.String()}
+			if f.Parent != nil {
+				e.Parent = f.Parent.String()
 			}
-			err = grapher.GraphFinding(f)
-			if err != nil {
-				panic(err)
+			events.Log(e)
+
+			if errors.Is(err, grawler.ErrRejected) {
+				events.Log(eventlog.Event{
+					Type:   eventlog.Rejected,
+					URL:    f.Resource.String(),
+					Reason: metrics.RejectedBlacklist,
+					Error:  err.Error(),
+				})
+				met.Rejected.With(metrics.RejectedBlacklist).Inc()
+				return nil
+			}
+
+			// Duplicates are expected, most findings have been
+			// found before. They are only counted.
+			if errors.Is(err, grawler.ErrDuplicateJob) {
+				met.Rejected.With(metrics.RejectedDuplicate).Inc()
 			}
-		case j := <-done:
-			if j.job != nil {
-				coord.FinishJob(j.job)
+			graphFailed(grapher.GraphFinding(f))
+			return nil
+		},
+		OnRetry: func(res *grawler.JobResult, delay time.Duration) {
+			met.SetJobs(coord.Counts())
+			met.Retries.Inc()
+			events.Log(eventlog.Event{
+				Type:       eventlog.JobRetry,
+				Crawler:    res.Worker,
+				URL:        res.Resource.String(),
+				Attempt:    res.Attempt,
+				Delay:      delay.Seconds(),
+				Error:      res.Err.Error(),
+				ErrorClass: grawler.ErrorClass(res.Err),
+			})
+		},
+		OnJobDone: func(res *grawler.JobResult) {
+			met.SetJobs(coord.Counts())
+			if res.Err != nil {
+				events.Log(eventlog.Event{
+					Type:       eventlog.JobFailed,
+					Crawler:    res.Worker,
+					URL:        res.Resource.String(),
+					Attempt:    res.Attempt,
+					Error:      res.Err.Error(),
+					ErrorClass: grawler.ErrorClass(res.Err),
+				})
 			}
-			idleCrawlers <- j.crawlerID
-		case <-ticks:
-			log.Printf("STATUS: %s", coord.String())
+		},
+	}
+
+	// Bootstrap the crawling.
+	root := &grawler.Resource{
+		Host: &grawler.Host{
+			Hostname: *flagBootstrap,
+			Port:     *flagPort,
+		},
+		Type:     grawler.DirectoryType,
+		Selector: "",
+	}
+	if err := crawler.Crawl(context.Background(), coord, root); err != nil {
+		panic(err)
+	}
+
+	if breaker != nil {
+		for _, bh := range breaker.BrokenHosts() {
+			log.Printf("Broken server %s: %d consecutive failures (%v)", bh.Host, bh.Failures, bh.LastErr)
+			graphFailed(grapher.AddNodeAttributes(bh.Host, bh.Attributes()))
+		}
+	}
+
+	graphFailed(grapher.Close())
+
+	for _, d := range diskSets {
+		if err := d.Err(); err != nil {
+			log.Printf("ERR: Could not use visited file: %v", err)
+		}
+		if err := d.Close(); err != nil {
+			log.Printf("ERR: Could not remove visited file: %v", err)
+		}
+	}
+
+	if ilog != nil {
+		err := ilog.Close()
+		if err != nil {
+			log.Printf("ERR: Could not write item log: %v", err)
 		}
+	}
 
-		if coord.JobsExhausted() {
-			break
+	if mirr != nil {
+		err := mirr.Close()
+		if err != nil {
+			log.Printf("ERR: Could not write mirror manifest: %v", err)
+		}
+	}
+
+	if warcWriter != nil {
+		err := warcWriter.Close()
+		if err != nil {
+			log.Printf("ERR: Could not close WARC archive: %v", err)
 		}
 	}
+
+	if idx != nil {
+		err := idx.Save(*flagIndex)
+		if err != nil {
+			log.Printf("ERR: Could not save index: %v", err)
+		}
+	}
+
+	// Resolving aliases needs DNS lookups, which are not recorded.
+	switch {
+	case *flagAliases != "" && *flagReplay != "":
+		log.Printf("Not resolving aliases while replaying")
+	case *flagAliases != "":
+		err := resolveAliases(*flagDotfile, *flagAliases == "merge", base)
+		if err != nil {
+			log.Printf("ERR: Could not resolve aliases: %v", err)
+		}
+	}
+
+	if *flagAnalytics {
+		seed := &grawler.Host{Hostname: *flagBootstrap, Port: *flagPort}
+		err := annotateGraph(*flagDotfile, []string{seed.String()})
+		if err != nil {
+			log.Printf("ERR: Could not annotate dotfile: %v", err)
+		}
+	}
+
+	if filters := graphFilters.filters(); len(filters) > 0 {
+		err := filterGraph(*flagDotfile, filters)
+		if err != nil {
+			log.Printf("ERR: Could not filter dotfile: %v", err)
+		}
+	}
+}
+
+// filterGraph reads the dotfile name, applies the grawler.GraphFilters and
+// rewrites the dotfile.
+func filterGraph(name string, filters []grawler.GraphFilter) error {
+	g, err := readGraph(name)
+	if err != nil {
+		return err
+	}
+
+	g.Filter(filters...)
+	return writeGraph(name, g)
+}
+
+// annotateGraph reads the dotfile name, runs the graph analytics with the
+// crawl started from seeds and rewrites the dotfile with the results as node
+// attributes.
+func annotateGraph(name string, seeds []string) error {
+	g, err := readGraph(name)
+	if err != nil {
+		return err
+	}
+
+	analytics.Analyze(g, seeds).Annotate(g)
+	return writeGraph(name, g)
+}
+
+// resolveAliases reads the dotfile name, groups the hosts that are aliases of
+// the same gopher server and rewrites the dotfile. If merge is true the
+// aliases are merged into a single node, otherwise sameAs edges are added. Root
+// menus are fetched using grawler.ResourceOpener o.
+func resolveAliases(name string, merge bool, o grawler.ResourceOpener) error {
+	g, err := readGraph(name)
+	if err != nil {
+		return err
+	}
+
+	groups := grawler.ResolveAliases(g.Hosts(), grawler.NetHostResolver, o)
+	for _, ag := range groups {
+		log.Printf("Aliases of %v: %v", ag.Canonical, ag.Aliases)
+	}
+
+	if merge {
+		g.MergeAliases(groups)
+	} else {
+		g.MarkAliases(groups)
+	}
+
+	return writeGraph(name, g)
 }
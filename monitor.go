@@ -0,0 +1,94 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/monitor"
+)
+
+// monitorHosts implements the monitor command. It periodically probes the root
+// menus of all gopher servers known from a previous crawl, keeps their uptime
+// history in a store and writes a status report after every round.
+func monitorHosts(args []string) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	flagDotfile := fs.String("dotfile", "grawler.dot", "the dotfile of a previous crawl listing the servers to monitor, empty to monitor the servers in the store only")
+	flagStore := fs.String("store", "monitor.json", "the file keeping the uptime history")
+	flagReport := fs.String("report", "-", "the file to write the status report to (\"-\" for stdout)")
+	flagInterval := fs.Duration("interval", time.Hour, "the time between two rounds of probes")
+	flagOnce := fs.Bool("once", false, "probe all servers once and exit")
+	flagWorkers := fs.Int("crawlers", runtime.NumCPU()*4, "the number of servers to probe concurrently")
+	fs.Parse(args)
+
+	store, err := monitor.LoadStore(*flagStore)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	known := make(map[string]bool)
+	hosts := store.Hosts()
+	for _, h := range hosts {
+		known[h.String()] = true
+	}
+	if *flagDotfile != "" {
+		g, err := readGraph(*flagDotfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, h := range g.Hosts() {
+			if !known[h.String()] {
+				hosts = append(hosts, h)
+				known[h.String()] = true
+			}
+		}
+	}
+
+	m := monitor.New(grawler.NetResourceOpener, *flagWorkers)
+	for {
+		log.Printf("Probing %d gopher servers", len(hosts))
+		alive := m.Round(store, hosts)
+		log.Printf("STATUS: Alive:%d Dead:%d", alive, len(hosts)-alive)
+
+		if err := store.Save(*flagStore); err != nil {
+			log.Printf("ERR: Could not save store: %v", err)
+		}
+		if err := writeReport(store, *flagReport); err != nil {
+			log.Printf("ERR: Could not write report: %v", err)
+		}
+
+		if *flagOnce {
+			return
+		}
+		time.Sleep(*flagInterval)
+	}
+}
+
+// writeReport writes the status report of monitor.Store s to the file name.
+func writeReport(s monitor.Store, name string) error {
+	if name == "-" {
+		return s.WriteReport(os.Stdout)
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = s.WriteReport(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/analytics"
+)
+
+// rankOrders maps the values of the -sort flag of the rank command to the
+// corresponding ordering of analytics.NodeResults.
+var rankOrders = map[string]func(a, b analytics.NodeResult) bool{
+	"pagerank":    func(a, b analytics.NodeResult) bool { return a.PageRank > b.PageRank },
+	"indegree":    func(a, b analytics.NodeResult) bool { return a.InDegree > b.InDegree },
+	"outdegree":   func(a, b analytics.NodeResult) bool { return a.OutDegree > b.OutDegree },
+	"betweenness": func(a, b analytics.NodeResult) bool { return a.Betweenness > b.Betweenness },
+}
+
+// rank implements the rank command. It runs the graph analytics on the dotfile
+// written by a previous crawl and reports the most important gopherholes and
+// the clusters of the graph.
+func rank(args []string) {
+	fs := flag.NewFlagSet("rank", flag.ExitOnError)
+	flagFormat := fs.String("format", "text", "the output format: \"text\" or \"json\"")
+	flagTop := fs.Int("n", 20, "the number of gopherholes to report, 0 for all")
+	flagSort := fs.String("sort", "pagerank", "the order of the report: \"pagerank\", \"indegree\", \"outdegree\" or \"betweenness\"")
+	flagSeeds := fs.String("seeds", "gopher.floodgap.com:70", "comma separated list of the gopherholes the crawl started from")
+	flagOutput := fs.String("o", "", "write the graph annotated with the results to this dotfile")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: grawler rank [flags] grawler.dot\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	less, ok := rankOrders[*flagSort]
+	if fs.NArg() != 1 || !ok || (*flagFormat != "text" && *flagFormat != "json") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	g, err := readGraph(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	r := analytics.Analyze(g, splitSeeds(*flagSeeds))
+
+	if *flagOutput != "" {
+		r.Annotate(g)
+		err := writeGraph(*flagOutput, g)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+
+	sort.SliceStable(r.Nodes, func(i, j int) bool {
+		return less(r.Nodes[i], r.Nodes[j])
+	})
+	if *flagTop > 0 && len(r.Nodes) > *flagTop {
+		r.Nodes = r.Nodes[:*flagTop]
+	}
+
+	if *flagFormat == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "\t")
+		err = e.Encode(r)
+	} else {
+		err = writeRanking(os.Stdout, r)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// splitSeeds splits a comma separated list of gopherholes and normalizes them
+// like grawler.Host.String does.
+func splitSeeds(s string) []string {
+	var seeds []string
+	for _, seed := range strings.Split(s, ",") {
+		h, err := grawler.ParseHost(strings.TrimSpace(seed))
+		if err != nil {
+			continue
+		}
+		seeds = append(seeds, h.String())
+	}
+	return seeds
+}
+
+// writeRanking writes a human readable report of the analytics results to w.
+func writeRanking(w io.Writer, r *analytics.Results) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "GOPHERHOLE\tPAGERANK\tIN\tOUT\tBETWEENNESS\tSCC\tDISTANCE\tALIVE\n")
+	for _, n := range r.Nodes {
+		d := "-"
+		if n.Distance >= 0 {
+			d = fmt.Sprint(n.Distance)
+		}
+		fmt.Fprintf(tw, "%s\t%.6f\t%d\t%d\t%.1f\t%d\t%s\t%t\n",
+			n.Node, n.PageRank, n.InDegree, n.OutDegree, n.Betweenness,
+			n.Component, d, n.Alive)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	total := 0
+	for _, c := range r.Components {
+		total += len(c)
+	}
+	largest := 0
+	if len(r.Components) > 0 {
+		largest = len(r.Components[0])
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d gopherholes reachable from %s\n"+
+		"%d strongly connected components, the largest one with %d gopherholes\n"+
+		"%d gopherholes not reachable from the seeds\n",
+		r.Reachable, strings.Join(r.Seeds, ", "),
+		len(r.Components), largest, total-r.Reachable)
+	return err
+}
+
+// writeGraph writes Graph g to the dotfile name.
+func writeGraph(name string, g *grawler.Graph) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
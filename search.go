@@ -0,0 +1,53 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/blabber/grawler/internal/index"
+)
+
+// search implements the search command. It looks up the terms given as
+// arguments in an index written by a previous crawl and prints the matching
+// items.
+func search(args []string) {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	flagIndex := fs.String("index", "grawler.idx", "the index file written by a previous crawl")
+	flagFormat := fs.String("format", "url", "the output format: \"url\" or \"menu\" (gopher menu lines)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: grawler search [flags] terms...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 || (*flagFormat != "url" && *flagFormat != "menu") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	idx, err := index.Open(*flagIndex)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, d := range idx.Search(strings.Join(fs.Args(), " ")) {
+		switch *flagFormat {
+		case "menu":
+			fmt.Printf("%s\r\n", d.MenuLine())
+		default:
+			fmt.Println(d.URL())
+		}
+	}
+	if *flagFormat == "menu" {
+		fmt.Print(".\r\n")
+	}
+}
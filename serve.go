@@ -0,0 +1,76 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	"github.com/blabber/grawler/grawler"
+	"github.com/blabber/grawler/internal/index"
+	"github.com/blabber/grawler/internal/server"
+)
+
+// serve implements the serve command. It runs a gopher server answering search
+// queries using the index and serving menus describing the gopher servers
+// found by a previous crawl.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	flagAddr := fs.String("addr", ":7070", "the address to listen on")
+	flagHostname := fs.String("hostname", "localhost", "the hostname used in menus to reference this server")
+	flagIndex := fs.String("index", "grawler.idx", "the index file written by a previous crawl, empty to disable searching")
+	flagDotfile := fs.String("dotfile", "grawler.dot", "the dotfile written by a previous crawl, empty to disable server menus")
+	flagMaxResults := fs.Int("max-results", 1000, "the maximum number of items returned for a search query, 0 for no limit")
+	fs.Parse(args)
+
+	s := &server.Server{
+		Hostname:   *flagHostname,
+		MaxResults: *flagMaxResults,
+	}
+
+	if *flagIndex != "" {
+		idx, err := index.Open(*flagIndex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s.Index = idx
+	}
+
+	if *flagDotfile != "" {
+		g, err := readGraph(*flagDotfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s.Graph = g
+	}
+
+	l, err := net.Listen("tcp", *flagAddr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_, s.Port, _ = net.SplitHostPort(l.Addr().String())
+
+	log.Printf("Serving gopher://%s:%s/", s.Hostname, s.Port)
+	log.Fatal(s.Serve(l))
+}
+
+// readGraph reads the dotfile name.
+func readGraph(name string) (*grawler.Graph, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return grawler.ReadGraph(f)
+}
@@ -0,0 +1,75 @@
+// "THE BEER-WARE LICENSE" (Revision 42):
+// <tobias.rehbein@web.de> wrote this file. As long as you retain this notice
+// you can do whatever you want with this stuff. If we meet some day, and you
+// think this stuff is worth it, you can buy me a beer in return.
+//                                                             Tobias Rehbein
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/blabber/grawler/internal/itemlog"
+	crawlstats "github.com/blabber/grawler/internal/stats"
+)
+
+// stats implements the stats command. It reports statistics of the dotfile
+// written by a previous crawl and, if given, of its item log.
+func stats(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	flagFormat := fs.String("format", "text", "the output format: \"text\" or \"json\"")
+	flagItems := fs.String("items", "", "the item log written by the crawl (any -ilog-format), empty to skip item statistics")
+	flagTop := fs.Int("n", 5, "the number of entries of top lists, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: grawler stats [flags] grawler.dot\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || (*flagFormat != "text" && *flagFormat != "json") {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	g, err := readGraph(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	s := crawlstats.New(g, *flagTop)
+
+	if *flagItems != "" {
+		records, err := readItemLog(*flagItems)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		s.AddItems(records)
+	}
+
+	if *flagFormat == "json" {
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "\t")
+		err = e.Encode(s)
+	} else {
+		err = s.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readItemLog reads the item log name.
+func readItemLog(name string) ([]itemlog.Record, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return itemlog.ReadAll(f)
+}